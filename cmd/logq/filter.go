@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ccnj/go-utils/log"
+	"go.uber.org/zap/zapcore"
+)
+
+// entry 一条解析后的日志
+type entry struct {
+	raw    []byte
+	fields map[string]interface{}
+	time   time.Time
+	level  zapcore.Level
+}
+
+func parseEntry(line []byte) (*entry, error) {
+	fields := make(map[string]interface{})
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber() // 保持数字原样，避免大整数 uid 被转为浮点
+	if err := dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+	e := &entry{raw: line, fields: fields}
+	if ts, ok := fields[log.TimeKey].(string); ok {
+		e.time, _ = parseTime(ts)
+	}
+	if lvl, ok := fields[log.LevelKey].(string); ok {
+		_ = e.level.UnmarshalText([]byte(lvl))
+	}
+	return e, nil
+}
+
+// str 把字段值转为字符串用于比较和展示
+func (e *entry) str(key string) string {
+	return stringify(e.fields[key])
+}
+
+func stringify(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case json.Number:
+		return x.String()
+	case bool:
+		return fmt.Sprint(x)
+	default:
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+}
+
+// parseTime 支持日志本身的时间格式，以及 RFC3339、日期等常用写法
+func parseTime(s string) (time.Time, error) {
+	layouts := []string{log.TimeLayout, time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+	cst := time.FixedZone("CST", 8*3600)
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, cst); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// parseTimeArg 解析 -since/-until，除绝对时间外还支持 30m、2h 这类相对当前的时长
+func parseTimeArg(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return parseTime(s)
+}
+
+// predicate 字段条件，支持 key=value、key!=value、key~substr
+type predicate struct {
+	key   string
+	op    string
+	value string
+}
+
+// parsePredicate 以最先出现的运算符分隔 key 和 value，value 中可以再含 = 或 ~，如 url~a=b
+func parsePredicate(s string) (predicate, error) {
+	for i := 0; i < len(s); i++ {
+		var op string
+		switch {
+		case strings.HasPrefix(s[i:], "!="):
+			op = "!="
+		case s[i] == '=':
+			op = "="
+		case s[i] == '~':
+			op = "~"
+		default:
+			continue
+		}
+		if i == 0 {
+			break
+		}
+		return predicate{key: s[:i], op: op, value: s[i+len(op):]}, nil
+	}
+	return predicate{}, fmt.Errorf("invalid predicate %q, expect key=value, key!=value or key~substr", s)
+}
+
+func (p predicate) match(e *entry) bool {
+	v, exist := e.fields[p.key]
+	s := stringify(v)
+	switch p.op {
+	case "=":
+		return exist && s == p.value
+	case "!=":
+		return !exist || s != p.value
+	default:
+		return exist && strings.Contains(s, p.value)
+	}
+}
+
+// filter 所有条件同时满足才输出
+type filter struct {
+	since, until time.Time
+	minLevel     *zapcore.Level
+	project      string
+	requestId    string
+	uid          string
+	predicates   []predicate
+}
+
+func (f *filter) match(e *entry) bool {
+	if !f.since.IsZero() && e.time.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && e.time.After(f.until) {
+		return false
+	}
+	if f.minLevel != nil && e.level < *f.minLevel {
+		return false
+	}
+	if f.project != "" && e.str(log.ProjectKey) != f.project {
+		return false
+	}
+	if f.requestId != "" && e.str(log.RequestIdKey) != f.requestId {
+		return false
+	}
+	if f.uid != "" && e.str(log.UidKey) != f.uid {
+		return false
+	}
+	for _, p := range f.predicates {
+		if !p.match(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// skipFile 轮转文件中的日志都早于其轮转时间，轮转时间早于 since 的文件可以整个跳过
+func (f *filter) skipFile(lf logFile) bool {
+	return !f.since.IsZero() && !lf.rotatedAt.IsZero() && lf.rotatedAt.Before(f.since)
+}
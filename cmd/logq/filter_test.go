@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParsePredicate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    predicate
+		wantErr bool
+	}{
+		{in: "order_id=123", want: predicate{key: "order_id", op: "=", value: "123"}},
+		{in: "status!=200", want: predicate{key: "status", op: "!=", value: "200"}},
+		{in: "msg~超时", want: predicate{key: "msg", op: "~", value: "超时"}},
+		{in: "url~a=b", want: predicate{key: "url", op: "~", value: "a=b"}},
+		{in: "url~a!=b", want: predicate{key: "url", op: "~", value: "a!=b"}},
+		{in: "query=a~b", want: predicate{key: "query", op: "=", value: "a~b"}},
+		{in: "query!=a=b", want: predicate{key: "query", op: "!=", value: "a=b"}},
+		{in: "query=a!=b", want: predicate{key: "query", op: "=", value: "a!=b"}},
+		{in: "empty=", want: predicate{key: "empty", op: "=", value: ""}},
+		{in: "noop", wantErr: true},
+		{in: "=value", wantErr: true},
+		{in: "!=value", wantErr: true},
+		{in: "~value", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parsePredicate(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePredicate(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parsePredicate(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	e, err := parseEntry([]byte(`{"timestamp":"2024-05-01T20:00:00.000+08:00","level":"warn","msg":"支付超时","project":"user_srv","request_id":"r1","uid":10086,"order_id":123}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(s string) time.Time {
+		tm, err := parseTime(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	warn, errLvl := zapcore.WarnLevel, zapcore.ErrorLevel
+	mustPredicate := func(s string) predicate {
+		p, err := parsePredicate(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name string
+		f    filter
+		want bool
+	}{
+		{"empty filter", filter{}, true},
+		{"since before", filter{since: at("2024-05-01 19:00:00")}, true},
+		{"since after", filter{since: at("2024-05-01 21:00:00")}, false},
+		{"until after", filter{until: at("2024-05-01 21:00:00")}, true},
+		{"until before", filter{until: at("2024-05-01 19:00:00")}, false},
+		{"level reached", filter{minLevel: &warn}, true},
+		{"level too low", filter{minLevel: &errLvl}, false},
+		{"project", filter{project: "user_srv"}, true},
+		{"other project", filter{project: "order_srv"}, false},
+		{"request id", filter{requestId: "r1"}, true},
+		{"numeric uid", filter{uid: "10086"}, true},
+		{"other uid", filter{uid: "1"}, false},
+		{"equal predicate", filter{predicates: []predicate{mustPredicate("order_id=123")}}, true},
+		{"not equal predicate", filter{predicates: []predicate{mustPredicate("order_id!=123")}}, false},
+		{"not equal on missing field", filter{predicates: []predicate{mustPredicate("coupon!=1")}}, true},
+		{"contains predicate", filter{predicates: []predicate{mustPredicate("msg~超时")}}, true},
+		{"contains on missing field", filter{predicates: []predicate{mustPredicate("coupon~1")}}, false},
+		{"all predicates must match", filter{predicates: []predicate{mustPredicate("msg~超时"), mustPredicate("order_id=1")}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.f.match(e); got != tt.want {
+				t.Errorf("match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
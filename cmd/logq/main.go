@@ -0,0 +1,127 @@
+// logq 查询 InitPrdLogger 输出的 JSON 日志，自动按时间顺序读取轮转文件（含 .gz 压缩文件）
+//
+// 示例：
+//
+//	logq -level warn -since 2h /usr/local/yeying/projects/user_srv/logs/user_srv.log
+//	logq -uid 10086 -where order_id=123 -o json /usr/local/yeying/projects/user_srv/logs
+//	logq -trace 0b6c5f1e-...  # 在 all.log 中还原一次请求在所有项目中的日志
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ccnj/go-utils/log"
+	"go.uber.org/zap/zapcore"
+)
+
+type multiFlag []string
+
+func (m *multiFlag) String() string     { return strings.Join(*m, ",") }
+func (m *multiFlag) Set(s string) error { *m = append(*m, s); return nil }
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "logq:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("logq", flag.ContinueOnError)
+	since := fs.String("since", "", "起始时间，如 2024-01-02T15:04:05、2024-01-02 或相对时长 30m、2h")
+	until := fs.String("until", "", "结束时间，格式同 -since")
+	level := fs.String("level", "", "最低日志级别：debug、info、warn、error")
+	project := fs.String("project", "", "项目名称，如 user_srv")
+	requestId := fs.String("request-id", "", "request_id")
+	uid := fs.String("uid", "", "uid")
+	trace := fs.String("trace", "", "按 request_id 在 all.log 中还原一次请求经过的所有项目的日志，按时间排序")
+	allDir := fs.String("all-dir", log.DefaultAllProjectLogDir, "all.log 所在目录，-trace 且未指定文件时使用")
+	output := fs.String("o", "pretty", "输出格式：pretty 或 json")
+	var wheres multiFlag
+	fs.Var(&wheres, "where", "字段条件，可重复：key=value、key!=value、key~substr")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: logq [选项] [日志文件或目录 ...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f := &filter{project: *project, requestId: *requestId, uid: *uid}
+	now := time.Now()
+	var err error
+	if *since != "" {
+		if f.since, err = parseTimeArg(*since, now); err != nil {
+			return err
+		}
+	}
+	if *until != "" {
+		if f.until, err = parseTimeArg(*until, now); err != nil {
+			return err
+		}
+	}
+	if *level != "" {
+		lvl, err := zapcore.ParseLevel(*level)
+		if err != nil {
+			return err
+		}
+		f.minLevel = &lvl
+	}
+	for _, w := range wheres {
+		p, err := parsePredicate(w)
+		if err != nil {
+			return err
+		}
+		f.predicates = append(f.predicates, p)
+	}
+	if *output != "pretty" && *output != "json" {
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+
+	inputs := fs.Args()
+	if *trace != "" {
+		f.requestId = *trace
+		if len(inputs) == 0 {
+			inputs = []string{filepath.Join(*allDir, "all.log")}
+		}
+	}
+	if len(inputs) == 0 {
+		fs.Usage()
+		return fmt.Errorf("no log file given")
+	}
+
+	groups, err := expandInputs(inputs)
+	if err != nil {
+		return err
+	}
+
+	w := newPrinter(os.Stdout, *output)
+	defer w.flush()
+
+	// 普通模式按时间归并各轮转组，边读边输出；
+	// trace 模式的 all.log 由多个项目同时写入，行序与时间不完全一致，先收集再排序
+	if *trace == "" {
+		return mergeGroups(groups, f, w.print)
+	}
+	var collected []*entry
+	err = mergeGroups(groups, f, func(e *entry) error {
+		collected = append(collected, e)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(collected, func(i, j int) bool { return collected[i].time.Before(collected[j].time) })
+	for _, e := range collected {
+		if err := w.print(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ccnj/go-utils/log"
+)
+
+// 这些字段在 pretty 输出中有固定位置，不再重复列出
+var fixedKeys = map[string]bool{
+	log.TimeKey:       true,
+	log.LevelKey:      true,
+	log.CallerKey:     true,
+	log.MessageKey:    true,
+	log.StacktraceKey: true,
+	log.ProjectKey:    true,
+}
+
+type printer struct {
+	w      *bufio.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) *printer {
+	return &printer{w: bufio.NewWriter(w), format: format}
+}
+
+func (p *printer) flush() {
+	p.w.Flush()
+}
+
+func (p *printer) print(e *entry) error {
+	if p.format == "json" {
+		_, err := p.w.Write(append(bytes.TrimRight(e.raw, "\r\n"), '\n'))
+		return err
+	}
+
+	// 2024-01-02T15:04:05.000+08:00 ERROR [user_srv] 下单失败 order/create.go:42 request_id=... uid=... order_id=1
+	var sb strings.Builder
+	sb.WriteString(e.str(log.TimeKey))
+	sb.WriteString(" " + strings.ToUpper(padRight(e.str(log.LevelKey), 5)))
+	if project := e.str(log.ProjectKey); project != "" {
+		sb.WriteString(" [" + project + "]")
+	}
+	sb.WriteString(" " + e.str(log.MessageKey))
+	if caller := e.str(log.CallerKey); caller != "" {
+		sb.WriteString("  " + caller)
+	}
+
+	keys := make([]string, 0, len(e.fields))
+	for k := range e.fields {
+		if !fixedKeys[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteString(" " + k + "=" + e.str(k))
+	}
+	sb.WriteByte('\n')
+
+	if stack := e.str(log.StacktraceKey); stack != "" {
+		for _, line := range strings.Split(stack, "\n") {
+			sb.WriteString("    " + line + "\n")
+		}
+	}
+	_, err := p.w.WriteString(sb.String())
+	return err
+}
+
+func padRight(s string, n int) string {
+	if len(s) >= n {
+		return s
+	}
+	return s + strings.Repeat(" ", n-len(s))
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// lumberjack 轮转后的文件名格式：<name>-<backupTimeFormat><ext>[.gz]，时间为 UTC
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// logFile 一个待读取的日志文件
+type logFile struct {
+	path      string
+	rotatedAt time.Time // 轮转时间，即该文件最后一条日志的大致时间；当前正在写的文件为零值
+}
+
+// expandInputs 把命令行传入的文件或目录展开为轮转组，每组内的文件按时间先后排列
+// 传入 xxx.log 时会一并读取同目录下 xxx-<time>.log 与 xxx-<time>.log.gz 轮转文件，旧的在前
+func expandInputs(inputs []string) ([][]logFile, error) {
+	var groups [][]logFile
+	seen := make(map[string]bool)
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, err
+		}
+		var live []string
+		if info.IsDir() {
+			matches, err := filepath.Glob(filepath.Join(input, "*.log"))
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range matches {
+				if _, ok := parseBackupTime(m); !ok {
+					live = append(live, m)
+				}
+			}
+		} else {
+			live = []string{input}
+		}
+		for _, l := range live {
+			group, err := rotationGroup(l)
+			if err != nil {
+				return nil, err
+			}
+			var files []logFile
+			for _, f := range group {
+				if !seen[f.path] {
+					seen[f.path] = true
+					files = append(files, f)
+				}
+			}
+			if len(files) > 0 {
+				groups = append(groups, files)
+			}
+		}
+	}
+	return groups, nil
+}
+
+// rotationGroup 返回某个日志文件及其所有轮转文件，按时间先后排序
+func rotationGroup(live string) ([]logFile, error) {
+	dir := filepath.Dir(live)
+	ext := filepath.Ext(live)
+	prefix := strings.TrimSuffix(filepath.Base(live), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var group []logFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		t, ok := parseBackupTime(name)
+		if !ok || !strings.HasPrefix(strings.TrimSuffix(name, ".gz"), prefix+t.Format(backupTimeFormat)) {
+			continue
+		}
+		group = append(group, logFile{path: filepath.Join(dir, name), rotatedAt: t})
+	}
+	sort.Slice(group, func(i, j int) bool { return group[i].rotatedAt.Before(group[j].rotatedAt) })
+	if _, err := os.Stat(live); err == nil {
+		group = append(group, logFile{path: live})
+	}
+	return group, nil
+}
+
+// parseBackupTime 从轮转文件名中解析轮转时间
+func parseBackupTime(name string) (time.Time, bool) {
+	base := strings.TrimSuffix(filepath.Base(name), ".gz")
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	if len(base) < len(backupTimeFormat)+1 {
+		return time.Time{}, false
+	}
+	ts := base[len(base)-len(backupTimeFormat):]
+	if base[len(base)-len(backupTimeFormat)-1] != '-' {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(backupTimeFormat, ts)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// groupReader 依次读取一个轮转组中的文件，返回符合条件的日志，.gz 文件自动解压
+type groupReader struct {
+	files []logFile
+	f     *filter
+	path  string // 正在读取的文件
+	file  *os.File
+	gz    *gzip.Reader
+	br    *bufio.Reader
+}
+
+func newGroupReader(files []logFile, f *filter) *groupReader {
+	return &groupReader{files: files, f: f}
+}
+
+// next 返回下一条符合条件的日志，读完时返回 io.EOF
+func (r *groupReader) next() (*entry, error) {
+	for {
+		if r.br == nil {
+			if len(r.files) == 0 {
+				return nil, io.EOF
+			}
+			lf := r.files[0]
+			r.files = r.files[1:]
+			if r.f.skipFile(lf) {
+				continue
+			}
+			if err := r.open(lf.path); err != nil {
+				return nil, fmt.Errorf("%s: %w", lf.path, err)
+			}
+		}
+		line, err := r.br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("%s: %w", r.path, err)
+		}
+		if err == io.EOF {
+			r.close()
+		}
+		if len(line) == 0 {
+			continue
+		}
+		e, perr := parseEntry(line)
+		if perr != nil {
+			continue // 非 JSON 行（如 console/logfmt 格式或被截断的行）直接跳过
+		}
+		if r.f.match(e) {
+			return e, nil
+		}
+	}
+}
+
+func (r *groupReader) open(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	var rd io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		r.gz = gz
+		rd = gz
+	}
+	r.path, r.file = path, file
+	// 不用 bufio.Scanner，避免超长的堆栈日志超过其单行长度限制
+	r.br = bufio.NewReaderSize(rd, 64*1024)
+	return nil
+}
+
+func (r *groupReader) close() {
+	if r.gz != nil {
+		r.gz.Close()
+		r.gz = nil
+	}
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	r.br = nil
+}
+
+// mergeGroups 按时间归并多个轮转组的日志，组内已按时间有序，每次输出各组当前最早的一条
+// 时间相同时按组的顺序输出；只有一个组时等同于顺序读取
+func mergeGroups(groups [][]logFile, f *filter, fn func(e *entry) error) error {
+	readers := make([]*groupReader, len(groups))
+	heads := make([]*entry, len(groups))
+	defer func() {
+		for _, r := range readers {
+			if r != nil {
+				r.close()
+			}
+		}
+	}()
+	advance := func(i int) error {
+		e, err := readers[i].next()
+		if err == io.EOF {
+			heads[i] = nil
+			return nil
+		}
+		heads[i] = e
+		return err
+	}
+	for i, g := range groups {
+		readers[i] = newGroupReader(g, f)
+		if err := advance(i); err != nil {
+			return err
+		}
+	}
+	for {
+		first := -1
+		for i, e := range heads {
+			if e != nil && (first < 0 || e.time.Before(heads[first].time)) {
+				first = i
+			}
+		}
+		if first < 0 {
+			return nil
+		}
+		if err := fn(heads[first]); err != nil {
+			return err
+		}
+		if err := advance(first); err != nil {
+			return err
+		}
+	}
+}
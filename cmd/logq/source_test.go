@@ -0,0 +1,171 @@
+package main
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeLog 写入日志文件，.gz 结尾时压缩
+func writeLog(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	data := []byte(strings.Join(lines, "\n") + "\n")
+	if strings.HasSuffix(path, ".gz") {
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz := gzip.NewWriter(f)
+		if _, err := gz.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func logLine(ts, level, msg string) string {
+	return `{"timestamp":"2024-01-02T` + ts + `.000+08:00","level":"` + level + `","msg":"` + msg + `"}`
+}
+
+// newLogDir 模拟 lumberjack 轮转后的日志目录，轮转时间为 UTC
+func newLogDir(t *testing.T) string {
+	dir := t.TempDir()
+	writeLog(t, filepath.Join(dir, "p-2024-01-02T01-00-00.000.log.gz"), logLine("08:59:00", "info", "gz-1"), logLine("08:59:30", "info", "gz-2"))
+	writeLog(t, filepath.Join(dir, "p-2024-01-02T02-00-00.000.log"), logLine("09:59:00", "info", "backup"))
+	writeLog(t, filepath.Join(dir, "p.log"), logLine("10:00:00", "info", "live-1"), "not json", logLine("10:00:02", "info", "live-2"))
+	writeLog(t, filepath.Join(dir, "err_p-2024-01-02T01-30-00.000.log"), logLine("09:00:00", "error", "err-backup"))
+	writeLog(t, filepath.Join(dir, "err_p.log"), logLine("10:00:01", "error", "err-live"))
+	writeLog(t, filepath.Join(dir, "notes.txt"), "ignored")
+	return dir
+}
+
+func basenames(files []logFile) []string {
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = filepath.Base(f.path)
+	}
+	return names
+}
+
+func TestParseBackupTime(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   time.Time
+		wantOK bool
+	}{
+		{"p-2024-01-02T01-00-00.000.log", time.Date(2024, 1, 2, 1, 0, 0, 0, time.UTC), true},
+		{"/logs/err_p-2024-01-02T01-30-00.123.log.gz", time.Date(2024, 1, 2, 1, 30, 0, 123e6, time.UTC), true},
+		{"p.log", time.Time{}, false},
+		{"p-notatime.log", time.Time{}, false},
+		{"p2024-01-02T01-00-00.000.log", time.Time{}, false},
+		{"p-2024-13-02T01-00-00.000.log", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseBackupTime(tt.name)
+		if ok != tt.wantOK || !got.Equal(tt.want) {
+			t.Errorf("parseBackupTime(%q) = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestExpandInputs(t *testing.T) {
+	dir := newLogDir(t)
+	tests := []struct {
+		name   string
+		inputs []string
+		want   [][]string
+	}{
+		{"live file with backups", []string{filepath.Join(dir, "p.log")}, [][]string{
+			{"p-2024-01-02T01-00-00.000.log.gz", "p-2024-01-02T02-00-00.000.log", "p.log"},
+		}},
+		{"directory", []string{dir}, [][]string{
+			{"err_p-2024-01-02T01-30-00.000.log", "err_p.log"},
+			{"p-2024-01-02T01-00-00.000.log.gz", "p-2024-01-02T02-00-00.000.log", "p.log"},
+		}},
+		{"file and directory are deduplicated", []string{filepath.Join(dir, "err_p.log"), dir}, [][]string{
+			{"err_p-2024-01-02T01-30-00.000.log", "err_p.log"},
+			{"p-2024-01-02T01-00-00.000.log.gz", "p-2024-01-02T02-00-00.000.log", "p.log"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, err := expandInputs(tt.inputs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got [][]string
+			for _, g := range groups {
+				got = append(got, basenames(g))
+			}
+			if !slices.EqualFunc(got, tt.want, slices.Equal[[]string]) {
+				t.Errorf("groups = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := expandInputs([]string{filepath.Join(dir, "missing.log")}); err == nil {
+		t.Error("missing input should fail")
+	}
+}
+
+func TestMergeGroups(t *testing.T) {
+	dir := newLogDir(t)
+	since, _ := parseTime("2024-01-02T09:30:00")
+	tests := []struct {
+		name   string
+		inputs []string
+		filter *filter
+		want   []string
+	}{
+		{"single group reads gzip backups first", []string{filepath.Join(dir, "p.log")}, &filter{},
+			[]string{"gz-1", "gz-2", "backup", "live-1", "live-2"}},
+		{"directory is merged by time", []string{dir}, &filter{},
+			[]string{"gz-1", "gz-2", "err-backup", "backup", "live-1", "err-live", "live-2"}},
+		{"since skips rotated files", []string{dir}, &filter{since: since},
+			[]string{"backup", "live-1", "err-live", "live-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, err := expandInputs(tt.inputs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			err = mergeGroups(groups, tt.filter, func(e *entry) error {
+				got = append(got, e.str("msg"))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("messages = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeGroupsCorruptGzip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "p-2024-01-02T01-00-00.000.log.gz")
+	if err := os.WriteFile(path, []byte("not gzip\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := mergeGroups([][]logFile{{{path: path}}}, &filter{}, func(*entry) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("err = %v, want an error naming the file", err)
+	}
+}
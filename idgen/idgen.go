@@ -0,0 +1,43 @@
+// idgen 生成各类唯一 id：UUIDv4、UUIDv7、ULID、雪花 id，可用于 request id、订单号等
+
+package idgen
+
+import "github.com/google/uuid"
+
+// Generator 生成字符串形式的唯一 id
+type Generator interface {
+	Generate() string
+}
+
+// GeneratorFunc 函数形式的 Generator，用于自定义规则
+// 示例 idgen.GeneratorFunc(func() string { return "req-" + idgen.NewULID() })
+type GeneratorFunc func() string
+
+func (f GeneratorFunc) Generate() string {
+	return f()
+}
+
+// UUIDv4 完全随机的 uuid，如 "6ba7b810-9dad-41d1-80b4-00c04fd430c8"
+func UUIDv4() Generator {
+	return GeneratorFunc(NewUUIDv4)
+}
+
+// UUIDv7 以毫秒时间戳开头的 uuid，按时间有序，作为数据库或日志索引比 v4 友好
+func UUIDv7() Generator {
+	return GeneratorFunc(NewUUIDv7)
+}
+
+// ULID 以毫秒时间戳开头的 26 位 Crockford Base32 字符串，按时间有序，同一毫秒内单调递增
+func ULID() Generator {
+	return GeneratorFunc(NewULID)
+}
+
+// NewUUIDv4 生成 UUIDv4，随机源出错时 panic，与 uuid.New() 一致
+func NewUUIDv4() string {
+	return uuid.New().String()
+}
+
+// NewUUIDv7 生成 UUIDv7，随机源出错时 panic
+func NewUUIDv7() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
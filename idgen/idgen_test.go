@@ -0,0 +1,62 @@
+package idgen
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestUUID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	tests := []struct {
+		name        string
+		gen         Generator
+		wantVersion uuid.Version
+	}{
+		{"v4", UUIDv4(), 4},
+		{"v7", UUIDv7(), 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := make(map[string]bool)
+			for i := 0; i < 1000; i++ {
+				id := tt.gen.Generate()
+				if !pattern.MatchString(id) {
+					t.Fatalf("id %q is not a canonical uuid", id)
+				}
+				if v := uuid.MustParse(id).Version(); v != tt.wantVersion {
+					t.Fatalf("id %q version = %d, want %d", id, v, tt.wantVersion)
+				}
+				if seen[id] {
+					t.Fatalf("duplicate id %q", id)
+				}
+				seen[id] = true
+			}
+		})
+	}
+}
+
+func TestUUIDv7Ordering(t *testing.T) {
+	before := time.Now().Truncate(time.Millisecond)
+	prev := NewUUIDv7()
+	for i := 0; i < 1000; i++ {
+		id := NewUUIDv7()
+		if id <= prev {
+			t.Fatalf("%q is not greater than %q", id, prev)
+		}
+		prev = id
+	}
+	sec, nsec := uuid.MustParse(prev).Time().UnixTime()
+	if ts := time.Unix(sec, nsec); ts.Before(before) || ts.After(time.Now()) {
+		t.Errorf("uuid time %v is outside the generation window", ts)
+	}
+}
+
+func TestGeneratorFunc(t *testing.T) {
+	gen := GeneratorFunc(func() string { return "req-" + NewULID() })
+	if id := gen.Generate(); len(id) != len("req-")+26 {
+		t.Errorf("id = %q", id)
+	}
+}
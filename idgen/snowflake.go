@@ -0,0 +1,69 @@
+package idgen
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 雪花 id 的位分配：1 位符号（恒为 0）+ 41 位毫秒时间戳 + 10 位节点 + 12 位序号
+const (
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 12
+	MaxSnowflakeNode  = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSeq   = 1<<snowflakeSeqBits - 1
+)
+
+// SnowflakeEpoch 雪花 id 时间戳的起点，41 位毫秒可用约 69 年
+var SnowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Snowflake 生成 64 位、按时间有序的整数 id，适合订单号等需要数字且可排序的场景
+// 每个节点（进程）须使用不同的 node id，如取自 k8s StatefulSet 序号或配置中心
+type Snowflake struct {
+	mu     sync.Mutex
+	node   int64
+	lastMs int64
+	seq    int64
+	now    func() time.Time
+}
+
+// NewSnowflake node 取值 0 ~ 1023
+func NewSnowflake(node int64) (*Snowflake, error) {
+	if node < 0 || node > MaxSnowflakeNode {
+		return nil, fmt.Errorf("idgen: snowflake node must be between 0 and %d, got %d", MaxSnowflakeNode, node)
+	}
+	return &Snowflake{node: node, now: time.Now}, nil
+}
+
+// Next 生成下一个 id
+// 同一毫秒内序号用尽或系统时钟回拨时，沿用上次的时间戳继续递增，不会阻塞也不会重复
+func (s *Snowflake) Next() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms := s.now().Sub(SnowflakeEpoch).Milliseconds()
+	if ms <= s.lastMs {
+		s.seq++
+		if s.seq > snowflakeMaxSeq {
+			s.lastMs++
+			s.seq = 0
+		}
+	} else {
+		s.lastMs = ms
+		s.seq = 0
+	}
+	return s.lastMs<<(snowflakeNodeBits+snowflakeSeqBits) | s.node<<snowflakeSeqBits | s.seq
+}
+
+// Generate 实现 Generator，返回十进制字符串
+func (s *Snowflake) Generate() string {
+	return strconv.FormatInt(s.Next(), 10)
+}
+
+// ParseSnowflake 拆出雪花 id 的生成时间、节点和序号
+func ParseSnowflake(id int64) (t time.Time, node int64, seq int64) {
+	ms := id >> (snowflakeNodeBits + snowflakeSeqBits)
+	node = id >> snowflakeSeqBits & MaxSnowflakeNode
+	seq = id & snowflakeMaxSeq
+	return SnowflakeEpoch.Add(time.Duration(ms) * time.Millisecond), node, seq
+}
@@ -0,0 +1,79 @@
+package idgen
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNewSnowflake(t *testing.T) {
+	tests := []struct {
+		node    int64
+		wantErr bool
+	}{
+		{0, false},
+		{MaxSnowflakeNode, false},
+		{-1, true},
+		{MaxSnowflakeNode + 1, true},
+	}
+	for _, tt := range tests {
+		_, err := NewSnowflake(tt.node)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewSnowflake(%d) err = %v, wantErr %v", tt.node, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSnowflakeNext(t *testing.T) {
+	start := SnowflakeEpoch.Add(365 * 24 * time.Hour)
+	tests := []struct {
+		name     string
+		times    func(i int) time.Time // 第 i 次调用时的系统时间
+		n        int
+		wantTime time.Time // 最后一个 id 的时间戳
+		wantSeq  int64     // 最后一个 id 的序号
+	}{
+		{"new millisecond resets sequence", func(i int) time.Time { return start.Add(time.Duration(i) * time.Millisecond) }, 3, start.Add(2 * time.Millisecond), 0},
+		{"same millisecond increments sequence", func(int) time.Time { return start }, 3, start, 2},
+		{"sequence overflow borrows next millisecond", func(int) time.Time { return start }, snowflakeMaxSeq + 2, start.Add(time.Millisecond), 0},
+		{"clock moves backwards", func(i int) time.Time { return start.Add(-time.Duration(i) * time.Second) }, 3, start, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSnowflake(42)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var i int
+			s.now = func() time.Time { return tt.times(i) }
+			var prev, id int64
+			for i = 0; i < tt.n; i++ {
+				id = s.Next()
+				if id <= prev {
+					t.Fatalf("id %d is not greater than %d", id, prev)
+				}
+				prev = id
+			}
+			ts, node, seq := ParseSnowflake(id)
+			if !ts.Equal(tt.wantTime) || node != 42 || seq != tt.wantSeq {
+				t.Errorf("ParseSnowflake = %v, %d, %d, want %v, 42, %d", ts, node, seq, tt.wantTime, tt.wantSeq)
+			}
+		})
+	}
+}
+
+func TestSnowflakeGenerate(t *testing.T) {
+	s, err := NewSnowflake(MaxSnowflakeNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now().Truncate(time.Millisecond)
+	id, err := strconv.ParseInt(s.Generate(), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, node, _ := ParseSnowflake(id)
+	if node != MaxSnowflakeNode || ts.Before(before) || ts.After(time.Now()) {
+		t.Errorf("ParseSnowflake = %v, %d", ts, node)
+	}
+}
@@ -0,0 +1,113 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Crockford Base32，去掉了易混淆的 I L O U
+const ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var ErrInvalidULID = errors.New("idgen: invalid ulid")
+
+// ulidGenerator 保证同一进程内生成的 ULID 严格递增
+type ulidGenerator struct {
+	mu      sync.Mutex
+	lastMs  uint64
+	entropy [10]byte
+}
+
+var defaultULID = &ulidGenerator{}
+
+// NewULID 生成 ULID，如 "01HV3Q4Z8N6Y2K7R1M5D9B0C3X"
+// 同一毫秒内生成多个时随机部分加一，保证字符串排序与生成顺序一致；随机源出错时 panic
+func NewULID() string {
+	return defaultULID.generate(time.Now())
+}
+
+func (g *ulidGenerator) generate(now time.Time) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(now.UnixMilli())
+	if ms <= g.lastMs && !incrementBytes(g.entropy[:]) {
+		ms = g.lastMs
+	} else {
+		if ms <= g.lastMs {
+			// 同一毫秒内随机部分已用尽，借用下一毫秒
+			ms = g.lastMs + 1
+		}
+		if _, err := rand.Read(g.entropy[:]); err != nil {
+			panic(err)
+		}
+	}
+	g.lastMs = ms
+
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], ms<<16) // 时间戳只占前 48 位
+	copy(b[6:], g.entropy[:])
+	return encodeULID(b)
+}
+
+// incrementBytes 大端序加一，溢出时返回 true
+func incrementBytes(b []byte) (overflow bool) {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeULID 把 128 位按 5 位一组编码为 26 个字符，首字符只有 3 位有效
+func encodeULID(b [16]byte) string {
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := 0; i < 26; i++ {
+		offset := uint(125 - 5*i) // 该字符最低位在 128 位整数中的位置
+		var v uint64
+		switch {
+		case offset >= 64:
+			v = hi >> (offset - 64)
+		case offset+5 <= 64:
+			v = lo >> offset
+		default:
+			v = hi<<(64-offset) | lo>>offset
+		}
+		out[i] = ulidAlphabet[v&31]
+	}
+	return string(out[:])
+}
+
+// ULIDTime 解析 ULID 中的时间戳，可用于排查时定位请求发生的时间
+func ULIDTime(id string) (time.Time, error) {
+	if len(id) != 26 {
+		return time.Time{}, ErrInvalidULID
+	}
+	var ms uint64
+	for i := 0; i < 10; i++ { // 前 10 个字符是 48 位时间戳（50 位，最高 2 位为 0）
+		v := indexULIDChar(id[i])
+		if v < 0 || (i == 0 && v > 7) {
+			return time.Time{}, ErrInvalidULID
+		}
+		ms = ms<<5 | uint64(v)
+	}
+	return time.UnixMilli(int64(ms)), nil
+}
+
+func indexULIDChar(c byte) int {
+	if c >= 'a' && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	for i := 0; i < len(ulidAlphabet); i++ {
+		if ulidAlphabet[i] == c {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,107 @@
+package idgen
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestULIDMonotonic(t *testing.T) {
+	now := time.UnixMilli(time.Now().UnixMilli())
+	tests := []struct {
+		name   string
+		setup  func(g *ulidGenerator)
+		times  []time.Time
+		wantMs int64 // 最后一个 id 的时间戳
+	}{
+		{"same millisecond", nil, []time.Time{now, now, now}, now.UnixMilli()},
+		{"advancing clock", nil, []time.Time{now, now.Add(time.Millisecond), now.Add(2 * time.Millisecond)}, now.UnixMilli() + 2},
+		{"clock moves backwards", nil, []time.Time{now, now.Add(-time.Second)}, now.UnixMilli()},
+		{"entropy overflow borrows next millisecond", func(g *ulidGenerator) {
+			g.lastMs = uint64(now.UnixMilli())
+			for i := range g.entropy {
+				g.entropy[i] = 0xff
+			}
+		}, []time.Time{now}, now.UnixMilli() + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &ulidGenerator{}
+			if tt.setup != nil {
+				tt.setup(g)
+			}
+			var prev, id string
+			for _, ts := range tt.times {
+				id = g.generate(ts)
+				if len(id) != 26 {
+					t.Fatalf("id %q length = %d, want 26", id, len(id))
+				}
+				if id <= prev {
+					t.Fatalf("%q is not greater than %q", id, prev)
+				}
+				prev = id
+			}
+			got, err := ULIDTime(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.UnixMilli() != tt.wantMs {
+				t.Errorf("ULIDTime = %d, want %d", got.UnixMilli(), tt.wantMs)
+			}
+		})
+	}
+}
+
+func TestULIDTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		wantMs  int64
+		wantErr bool
+	}{
+		{"zero", "00000000000000000000000000", 0, false},
+		{"max timestamp", "7ZZZZZZZZZ0000000000000000", 1<<48 - 1, false},
+		{"lower case", "01arz3ndektsv4rrffq69g5fav", 1469922850259, false},
+		{"spec example", "01ARZ3NDEKTSV4RRFFQ69G5FAV", 1469922850259, false},
+		{"too short", "01ARZ3NDEK", 0, true},
+		{"invalid character", "01ARZ3NDEUTSV4RRFFQ69G5FAV", 0, true},
+		{"timestamp overflow", "80000000000000000000000000", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ULIDTime(tt.id)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidULID) {
+					t.Errorf("err = %v, want ErrInvalidULID", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.UnixMilli() != tt.wantMs {
+				t.Errorf("ULIDTime = %d, want %d", got.UnixMilli(), tt.wantMs)
+			}
+		})
+	}
+}
+
+func TestNewULID(t *testing.T) {
+	before := time.Now().Truncate(time.Millisecond)
+	prev := NewULID()
+	for i := 0; i < 10000; i++ {
+		id := NewULID()
+		if id <= prev {
+			t.Fatalf("%q is not greater than %q", id, prev)
+		}
+		prev = id
+	}
+	ts, err := ULIDTime(prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一毫秒用尽时会借用下一毫秒，允许少量超前
+	if ts.Before(before) || ts.After(time.Now().Add(time.Second)) {
+		t.Errorf("ULIDTime = %v, outside the generation window", ts)
+	}
+}
@@ -0,0 +1,339 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// HookEntry 传给 Hook 的日志条目
+// Fields 包含 logger 上的固定字段（如 project）以及本次打日志时传入的 kv
+type HookEntry struct {
+	zapcore.Entry
+	Fields map[string]interface{}
+}
+
+// Hook 日志告警钩子，命中规则的日志条目会调用 Fire
+type Hook interface {
+	Fire(entry *HookEntry) error
+}
+
+// HookFunc 通用回调钩子
+// 示例 log.HookFunc(func(e *log.HookEntry) error { fmt.Println(e.Message); return nil })
+type HookFunc func(entry *HookEntry) error
+
+func (f HookFunc) Fire(entry *HookEntry) error {
+	return f(entry)
+}
+
+// HookConfig 一条钩子配置：日志条目同时满足 Level、MsgContains、Fields 时触发 Hook
+type HookConfig struct {
+	Name        string            // 钩子名称，用于输出钩子失败信息
+	Level       zapcore.Level     // 最低触发级别，零值为 Info，告警一般设为 zapcore.ErrorLevel
+	MsgContains string            // msg 需包含的子串，为空则不限制
+	Fields      map[string]string // 字段需全部匹配，值按 fmt.Sprint 比较，为空则不限制
+	Hook        Hook
+}
+
+func (hc *HookConfig) match(entry *HookEntry) bool {
+	if entry.Level < hc.Level {
+		return false
+	}
+	if hc.MsgContains != "" && !strings.Contains(entry.Message, hc.MsgContains) {
+		return false
+	}
+	for k, want := range hc.Fields {
+		v, ok := entry.Fields[k]
+		if !ok || fmt.Sprint(v) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// hookQueueSize 异步触发钩子的队列长度，队列满时丢弃，避免拖慢打日志
+const hookQueueSize = 1024
+
+// hookDrainTimeout Sync 或替换 logger 时等待队列中钩子触发完成的最长时间
+const hookDrainTimeout = 5 * time.Second
+
+type hookJob struct {
+	hook    *HookConfig
+	entry   *HookEntry
+	flushed chan struct{} // 不为空时是 Sync 放入的标记，处理到它说明之前的钩子都已触发
+}
+
+// hookDispatcher 在单独的 goroutine 中触发钩子，webhook 之类的网络请求不会阻塞业务打日志
+// 每个 logger 一个，InitPrdLogger 替换 logger 时关闭旧的，goroutine 随之退出
+type hookDispatcher struct {
+	queue   chan hookJob
+	done    chan struct{}
+	metrics *logMetrics
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newHookDispatcher(m *logMetrics) *hookDispatcher {
+	d := &hookDispatcher{queue: make(chan hookJob, hookQueueSize), done: make(chan struct{}), metrics: m}
+	go func() {
+		defer close(d.done)
+		for job := range d.queue {
+			if job.flushed != nil {
+				close(job.flushed)
+				continue
+			}
+			d.fire(job.hook, job.entry)
+		}
+	}()
+	return d
+}
+
+func (d *hookDispatcher) dispatch(hc *HookConfig, entry *HookEntry) {
+	// Fatal 之后进程会直接退出，异步发送来不及，所以同步触发
+	if entry.Level >= zapcore.DPanicLevel {
+		d.fire(hc, entry)
+		return
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		// 替换前取到旧 logger 的调用方仍可能打日志，此时同步触发，告警不丢
+		d.fire(hc, entry)
+		return
+	}
+	select {
+	case d.queue <- hookJob{hook: hc, entry: entry}:
+	default:
+		d.metrics.incDropped("hook_queue_full")
+		reportHookErr(hc.Name, fmt.Errorf("hook queue is full, entry dropped: %s", entry.Message))
+	}
+}
+
+// drain 等待已入队的钩子全部触发，超时返回错误
+func (d *hookDispatcher) drain(timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	d.mu.RLock()
+	if d.closed {
+		d.mu.RUnlock()
+		return nil
+	}
+	flushed := make(chan struct{})
+	select {
+	case d.queue <- hookJob{flushed: flushed}:
+		d.mu.RUnlock()
+	case <-timer.C:
+		d.mu.RUnlock()
+		return fmt.Errorf("log hooks not drained within %s", timeout)
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("log hooks not drained within %s", timeout)
+	}
+}
+
+// close 停止接收新的钩子，等待队列中剩余的触发完成，超时后不再等待（goroutine 处理完后自行退出）
+func (d *hookDispatcher) close(timeout time.Duration) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	close(d.queue)
+	d.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-d.done:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("log hooks not drained within %s", timeout)
+	}
+}
+
+func (d *hookDispatcher) fire(hc *HookConfig, entry *HookEntry) {
+	defer func() {
+		if r := recover(); r != nil {
+			d.metrics.incHookFailure(hc.Name)
+			reportHookErr(hc.Name, fmt.Errorf("hook panic: %v", r))
+		}
+	}()
+	if err := hc.Hook.Fire(entry); err != nil {
+		d.metrics.incHookFailure(hc.Name)
+		reportHookErr(hc.Name, err)
+	}
+}
+
+// reportHookErr 钩子失败时不能再走 zap 打日志（可能再次触发钩子），直接写 stderr
+func reportHookErr(name string, err error) {
+	fmt.Fprintf(os.Stderr, "%s log hook %q failed: %v\n", time.Now().Format(time.RFC3339), name, err)
+}
+
+// hookCore 作为 zapcore.NewTee 的一路输出，负责匹配规则并触发钩子
+type hookCore struct {
+	hooks      []*HookConfig
+	minLevel   zapcore.Level
+	fields     []zapcore.Field
+	dispatcher *hookDispatcher
+}
+
+// newHookCore 没有有效钩子时返回 NopCore 和 nil dispatcher
+func newHookCore(hooks []HookConfig, m *logMetrics) (zapcore.Core, *hookDispatcher) {
+	hc := &hookCore{}
+	for i := range hooks {
+		if hooks[i].Hook == nil {
+			continue
+		}
+		h := hooks[i]
+		if len(hc.hooks) == 0 || h.Level < hc.minLevel {
+			hc.minLevel = h.Level
+		}
+		hc.hooks = append(hc.hooks, &h)
+	}
+	if len(hc.hooks) == 0 {
+		return zapcore.NewNopCore(), nil
+	}
+	hc.dispatcher = newHookDispatcher(m)
+	return hc, hc.dispatcher
+}
+
+func (c *hookCore) Enabled(lvl zapcore.Level) bool {
+	return lvl >= c.minLevel
+}
+
+func (c *hookCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return &clone
+}
+
+func (c *hookCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *hookCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	var entry *HookEntry
+	for _, hc := range c.hooks {
+		if ent.Level < hc.Level {
+			continue
+		}
+		// 只有真正可能触发时才编码字段
+		if entry == nil {
+			enc := zapcore.NewMapObjectEncoder()
+			for _, f := range c.fields {
+				f.AddTo(enc)
+			}
+			for _, f := range fields {
+				f.AddTo(enc)
+			}
+			entry = &HookEntry{Entry: ent, Fields: enc.Fields}
+		}
+		if hc.match(entry) {
+			c.dispatcher.dispatch(hc, entry)
+		}
+	}
+	return nil
+}
+
+// Sync 等待队列中的钩子触发完成，进程退出前调用 zap.L().Sync() 可避免丢失最后的告警
+func (c *hookCore) Sync() error {
+	return c.dispatcher.drain(hookDrainTimeout)
+}
+
+// ThrottledHook 限流钩子，同一事件（级别+msg）在 Interval 内最多触发 Burst 次
+// 被抑制的次数会在下一次触发时通过 Fields["suppressed"] 带上，一次事故不会发出成千上万条告警
+type ThrottledHook struct {
+	hook     Hook
+	interval time.Duration
+	burst    int
+	now      func() time.Time
+
+	mu      sync.Mutex
+	windows map[string]*throttleWindow
+}
+
+type throttleWindow struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+// NewThrottledHook 包装一个钩子使其限流，burst 小于 1 时按 1 处理
+// 示例 log.NewThrottledHook(webhook, time.Minute, 3)
+func NewThrottledHook(hook Hook, interval time.Duration, burst int) *ThrottledHook {
+	if burst < 1 {
+		burst = 1
+	}
+	return &ThrottledHook{
+		hook:     hook,
+		interval: interval,
+		burst:    burst,
+		now:      time.Now,
+		windows:  make(map[string]*throttleWindow),
+	}
+}
+
+func (t *ThrottledHook) Fire(entry *HookEntry) error {
+	key := entry.Level.String() + "|" + entry.Message
+	now := t.now()
+
+	t.mu.Lock()
+	w, ok := t.windows[key]
+	if !ok || now.Sub(w.start) >= t.interval {
+		suppressed := 0
+		if ok {
+			suppressed = w.suppressed
+		}
+		w = &throttleWindow{start: now, suppressed: suppressed}
+		t.windows[key] = w
+		t.gc(now)
+	}
+	if w.count >= t.burst {
+		w.suppressed++
+		t.mu.Unlock()
+		return nil
+	}
+	w.count++
+	suppressed := w.suppressed
+	w.suppressed = 0
+	t.mu.Unlock()
+
+	if suppressed > 0 {
+		// 复制一份，避免修改其他钩子共享的 entry
+		fields := make(map[string]interface{}, len(entry.Fields)+1)
+		for k, v := range entry.Fields {
+			fields[k] = v
+		}
+		fields["suppressed"] = suppressed
+		entry = &HookEntry{Entry: entry.Entry, Fields: fields}
+	}
+	return t.hook.Fire(entry)
+}
+
+// gc 清理过期且没有待上报抑制次数的窗口，防止 msg 种类多时 map 无限增长
+func (t *ThrottledHook) gc(now time.Time) {
+	if len(t.windows) < 1024 {
+		return
+	}
+	for k, w := range t.windows {
+		if now.Sub(w.start) >= t.interval && w.suppressed == 0 {
+			delete(t.windows, k)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package log
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// webhookRecorder 记录 webhook 收到的请求体
+type webhookRecorder struct {
+	mu     sync.Mutex
+	bodies []map[string]interface{}
+	status int
+}
+
+func (r *webhookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	var m map[string]interface{}
+	_ = json.Unmarshal(body, &m)
+	r.mu.Lock()
+	r.bodies = append(r.bodies, m)
+	status := r.status
+	r.mu.Unlock()
+	if status != 0 {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("boom"))
+	}
+}
+
+func (r *webhookRecorder) received() []map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]map[string]interface{}(nil), r.bodies...)
+}
+
+func testHookEntry(level zapcore.Level, msg string, fields map[string]interface{}) *HookEntry {
+	return &HookEntry{
+		Entry:  zapcore.Entry{Level: level, Message: msg, Time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)},
+		Fields: fields,
+	}
+}
+
+func TestWebhookHookPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  WebhookFormat
+		status  int
+		text    func(m map[string]interface{}) string
+		wantErr bool
+	}{
+		{
+			name:   "dingtalk",
+			format: WebhookDingTalk,
+			text: func(m map[string]interface{}) string {
+				if m["msgtype"] != "text" {
+					return ""
+				}
+				return m["text"].(map[string]interface{})["content"].(string)
+			},
+		},
+		{
+			name:   "feishu",
+			format: WebhookFeishu,
+			text: func(m map[string]interface{}) string {
+				if m["msg_type"] != "text" {
+					return ""
+				}
+				return m["content"].(map[string]interface{})["text"].(string)
+			},
+		},
+		{
+			name:   "slack",
+			format: WebhookSlack,
+			text:   func(m map[string]interface{}) string { return m["text"].(string) },
+		},
+		{
+			name:    "non 2xx response",
+			format:  WebhookSlack,
+			status:  http.StatusInternalServerError,
+			text:    func(m map[string]interface{}) string { return m["text"].(string) },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &webhookRecorder{status: tt.status}
+			srv := httptest.NewServer(rec)
+			defer srv.Close()
+
+			hook := &WebhookHook{URL: srv.URL, Format: tt.format, Client: srv.Client()}
+			err := hook.Fire(testHookEntry(zapcore.ErrorLevel, "下单失败", map[string]interface{}{"order_id": 42, "uid": "u1"}))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fire() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			bodies := rec.received()
+			if len(bodies) != 1 {
+				t.Fatalf("webhook received %d requests, want 1", len(bodies))
+			}
+			text := tt.text(bodies[0])
+			want := "[ERROR] 下单失败\ntime: 2024-05-01T20:00:00.000+08:00\norder_id: 42\nuid: u1"
+			if text != want {
+				t.Errorf("payload text = %q, want %q", text, want)
+			}
+		})
+	}
+}
+
+func TestThrottledHook(t *testing.T) {
+	type fire struct {
+		after          time.Duration // 距第一次触发的时间
+		msg            string
+		wantFired      bool
+		wantSuppressed int
+	}
+	tests := []struct {
+		name  string
+		burst int
+		fires []fire
+	}{
+		{
+			name:  "burst then suppress",
+			burst: 2,
+			fires: []fire{
+				{0, "db down", true, 0},
+				{time.Second, "db down", true, 0},
+				{2 * time.Second, "db down", false, 0},
+				{3 * time.Second, "db down", false, 0},
+			},
+		},
+		{
+			name:  "next window reports suppressed count",
+			burst: 1,
+			fires: []fire{
+				{0, "db down", true, 0},
+				{time.Second, "db down", false, 0},
+				{2 * time.Second, "db down", false, 0},
+				{time.Minute, "db down", true, 2},
+				{time.Minute + time.Second, "db down", false, 0},
+			},
+		},
+		{
+			name:  "different messages are throttled separately",
+			burst: 1,
+			fires: []fire{
+				{0, "db down", true, 0},
+				{0, "cache down", true, 0},
+				{time.Second, "db down", false, 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []*HookEntry
+			th := NewThrottledHook(HookFunc(func(e *HookEntry) error {
+				got = append(got, e)
+				return nil
+			}), time.Minute, tt.burst)
+			start := time.Unix(1700000000, 0)
+			for i, f := range tt.fires {
+				th.now = func() time.Time { return start.Add(f.after) }
+				before := len(got)
+				if err := th.Fire(testHookEntry(zapcore.ErrorLevel, f.msg, nil)); err != nil {
+					t.Fatalf("fire %d: %v", i, err)
+				}
+				fired := len(got) > before
+				if fired != f.wantFired {
+					t.Fatalf("fire %d: fired = %v, want %v", i, fired, f.wantFired)
+				}
+				if !fired {
+					continue
+				}
+				suppressed, _ := got[len(got)-1].Fields["suppressed"].(int)
+				if suppressed != f.wantSuppressed {
+					t.Errorf("fire %d: suppressed = %d, want %d", i, suppressed, f.wantSuppressed)
+				}
+			}
+		})
+	}
+}
+
+func TestHookConfigMatch(t *testing.T) {
+	hc := &HookConfig{Level: zapcore.ErrorLevel, MsgContains: "支付", Fields: map[string]string{"channel": "wx"}}
+	tests := []struct {
+		name  string
+		entry *HookEntry
+		want  bool
+	}{
+		{"match", testHookEntry(zapcore.ErrorLevel, "支付回调失败", map[string]interface{}{"channel": "wx"}), true},
+		{"higher level", testHookEntry(zapcore.FatalLevel, "支付回调失败", map[string]interface{}{"channel": "wx"}), true},
+		{"level too low", testHookEntry(zapcore.WarnLevel, "支付回调失败", map[string]interface{}{"channel": "wx"}), false},
+		{"msg not contained", testHookEntry(zapcore.ErrorLevel, "下单失败", map[string]interface{}{"channel": "wx"}), false},
+		{"field differs", testHookEntry(zapcore.ErrorLevel, "支付回调失败", map[string]interface{}{"channel": "ali"}), false},
+		{"field missing", testHookEntry(zapcore.ErrorLevel, "支付回调失败", nil), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hc.match(tt.entry); got != tt.want {
+				t.Errorf("match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitPrdLoggerHooks(t *testing.T) {
+	prev := zap.L()
+	defer zap.ReplaceGlobals(prev)
+
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	initLogger := func() {
+		InitPrdLogger("hook_test", &PrdLoggerConfig{
+			LogDir:           t.TempDir(),
+			AllProjectLogDir: t.TempDir(),
+			ConsoleLevel:     LevelRange{Min: zapcore.FatalLevel},
+			Hooks: []HookConfig{{
+				Name:  "webhook",
+				Level: zapcore.ErrorLevel,
+				Hook:  &WebhookHook{URL: srv.URL, Format: WebhookSlack, Client: srv.Client()},
+			}},
+		})
+	}
+	initLogger()
+	first := prdHookDispatcher
+
+	zap.S().Infow("正常请求")
+	zap.S().Errorw("下单失败", "order_id", 42)
+	// Sync 会同步 stdout，测试环境下可能报错，这里只关心钩子是否已触发
+	_ = zap.L().Sync()
+
+	bodies := rec.received()
+	if len(bodies) != 1 {
+		t.Fatalf("webhook received %d requests after Sync, want 1", len(bodies))
+	}
+	text, _ := bodies[0]["text"].(string)
+	if !strings.HasPrefix(text, "[ERROR] 下单失败") || !strings.Contains(text, "order_id: 42") || !strings.Contains(text, "project: hook_test") {
+		t.Errorf("unexpected webhook text %q", text)
+	}
+
+	// 重复初始化时关闭旧 logger 的 dispatcher
+	initLogger()
+	if prdHookDispatcher == first {
+		t.Fatal("dispatcher was not replaced")
+	}
+	select {
+	case <-first.done:
+	case <-time.After(time.Second):
+		t.Fatal("previous dispatcher goroutine did not exit")
+	}
+	// 旧 logger 关闭后仍可打日志，钩子同步触发
+	first.dispatch(&HookConfig{Name: "late", Hook: HookFunc(func(*HookEntry) error { return nil })}, testHookEntry(zapcore.ErrorLevel, "late", nil))
+	replaceHookDispatcher(nil)
+}
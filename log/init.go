@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -28,10 +30,8 @@ func InitDevLogger(projectName string) {
 	}
 
 	// 修改 EncoderConfig，使日志更易读
-	config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder                      // 使用大写带颜色的日志级别
-	config.EncoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) { // 东八区时间格式 毫秒级
-		enc.AppendString(t.In(time.FixedZone("CST", 8*3600)).Format("2006-01-02T15:04:05.000+08:00"))
-	}
+	config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder // 使用大写带颜色的日志级别
+	config.EncoderConfig.EncodeTime = encodeCSTTime                     // 东八区时间格式 毫秒级
 
 	// 构建 logger
 	logger, _ := config.Build()
@@ -54,10 +54,15 @@ func InitDevLogger(projectName string) {
 // 4. Info 及以上级别会记录到 app.log
 // 5. Error 及以上级别会记录到 error.log
 // 6. 同时在控制台输出 Info 及以上级别的日志
+// 7. 控制台和 all.log 的级别范围可通过 ConsoleLevel、AllLogLevel 调整
 func InitPrdLogger(projectName string, config ...*PrdLoggerConfig) {
 	// 确保日志路径存在
 	logDir := fmt.Sprintf("/usr/local/yeying/projects/%s/logs", projectName) // 临时路径老有问题，没深究
-	allProjectLogDir := "/usr/local/yeying/unilogs"
+	allProjectLogDir := DefaultAllProjectLogDir
+	var hooks []HookConfig
+	var registerer prometheus.Registerer
+	consoleEncoding, fileEncoding, allLogEncoding := EncodingJSON, EncodingJSON, EncodingJSON
+	var consoleLevel, allLogLevel LevelRange // 零值为 Info 及以上
 	if len(config) > 0 {
 		// 如果传入了配置，则使用传入的配置
 		if config[0].LogDir != "" {
@@ -66,25 +71,36 @@ func InitPrdLogger(projectName string, config ...*PrdLoggerConfig) {
 		if config[0].AllProjectLogDir != "" {
 			allProjectLogDir = config[0].AllProjectLogDir
 		}
+		hooks = config[0].Hooks
+		registerer = config[0].Registerer
+		if config[0].ConsoleEncoding != "" {
+			consoleEncoding = config[0].ConsoleEncoding
+		}
+		if config[0].FileEncoding != "" {
+			fileEncoding = config[0].FileEncoding
+		}
+		if config[0].AllLogEncoding != "" {
+			allLogEncoding = config[0].AllLogEncoding
+		}
+		consoleLevel = config[0].ConsoleLevel
+		allLogLevel = config[0].AllLogLevel
 	}
 	if err := os.MkdirAll(logDir, os.ModePerm); err != nil {
 		panic("Failed to create log directory: " + err.Error())
 	}
 
-	// 配置 JSON 编码器，定义日志格式
+	// 配置编码器，定义日志格式，默认各输出都使用 JSON
 	encoderConfig := zapcore.EncoderConfig{
-		TimeKey:       "timestamp",     // 时间戳字段名
-		LevelKey:      "level",         // 日志级别字段名
-		NameKey:       "logger",        // logger名字字段名
-		CallerKey:     "caller",        // 调用者字段名
-		FunctionKey:   zapcore.OmitKey, // 调用函数名字段名，这里选择省略
-		MessageKey:    "msg",           // 消息字段名
-		StacktraceKey: "stacktrace",    // 堆栈跟踪字段名
-		LineEnding:    zapcore.DefaultLineEnding,
-		EncodeLevel:   zapcore.LowercaseLevelEncoder, // 小写编码器
-		EncodeTime: func(t time.Time, enc zapcore.PrimitiveArrayEncoder) { // 东八区时间格式 毫秒级
-			enc.AppendString(t.In(time.FixedZone("CST", 8*3600)).Format("2006-01-02T15:04:05.000+08:00"))
-		},
+		TimeKey:        TimeKey,         // 时间戳字段名
+		LevelKey:       LevelKey,        // 日志级别字段名
+		NameKey:        NameKey,         // logger名字字段名
+		CallerKey:      CallerKey,       // 调用者字段名
+		FunctionKey:    zapcore.OmitKey, // 调用函数名字段名，这里选择省略
+		MessageKey:     MessageKey,      // 消息字段名
+		StacktraceKey:  StacktraceKey,   // 堆栈跟踪字段名
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,  // 小写编码器
+		EncodeTime:     encodeCSTTime,                  // 东八区时间格式 毫秒级
 		EncodeDuration: zapcore.SecondsDurationEncoder, // 持续时间使用秒作为单位
 		EncodeCaller:   zapcore.ShortCallerEncoder,     // 短路径编码器
 	}
@@ -116,6 +132,10 @@ func InitPrdLogger(projectName string, config ...*PrdLoggerConfig) {
 		Compress:   true,                                       // 是否压缩/归档旧文件
 	}
 
+	// 未传入 Registerer 时 metrics 为 nil，不做任何统计
+	metrics := newLogMetrics(registerer, projectName)
+	hookCore, dispatcher := newHookCore(hooks, metrics)
+
 	// 配置日志级别过滤器
 	highLevel := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
 		return lvl >= zapcore.ErrorLevel // Error 及以上级别
@@ -129,28 +149,30 @@ func InitPrdLogger(projectName string, config ...*PrdLoggerConfig) {
 	core := zapcore.NewTee(
 		// 1. Info 到 Warn 级别的日志写入 app.log
 		zapcore.NewCore(
-			zapcore.NewJSONEncoder(encoderConfig),
-			zapcore.AddSync(appLogWriter),
+			newEncoder(fileEncoding, encoderConfig),
+			newMeteredWriter(zapcore.AddSync(appLogWriter), sinkApp, metrics, appLogWriter),
 			lowLevel,
 		),
 		// 2. Error 及以上级别的日志写入 error.log
 		zapcore.NewCore(
-			zapcore.NewJSONEncoder(encoderConfig),
-			zapcore.AddSync(errorLogWriter),
+			newEncoder(fileEncoding, encoderConfig),
+			newMeteredWriter(zapcore.AddSync(errorLogWriter), sinkError, metrics, errorLogWriter),
 			highLevel,
 		),
-		// 3. 所有级别的日志同时输出到控制台
+		// 3. ConsoleLevel 范围内的日志同时输出到控制台，默认 Info 及以上
 		zapcore.NewCore(
-			zapcore.NewJSONEncoder(encoderConfig),
-			zapcore.AddSync(os.Stdout),
-			zap.LevelEnablerFunc(func(lvl zapcore.Level) bool { return true }), // 记录所有级别的日志
+			newEncoder(consoleEncoding, encoderConfig),
+			newMeteredWriter(zapcore.AddSync(os.Stdout), sinkConsole, metrics, nil),
+			consoleLevel,
 		),
-		// 4. 所有级别的日志写入 all.log
+		// 4. AllLogLevel 范围内的日志写入 all.log，默认 Info 及以上
 		zapcore.NewCore(
-			zapcore.NewJSONEncoder(encoderConfig),
-			zapcore.AddSync(allLogWriter),
-			zap.LevelEnablerFunc(func(lvl zapcore.Level) bool { return true }), // 记录所有级别的日志
+			newEncoder(allLogEncoding, encoderConfig),
+			newMeteredWriter(zapcore.AddSync(allLogWriter), sinkAll, metrics, allLogWriter),
+			allLogLevel,
 		),
+		// 5. 命中规则的日志触发告警钩子
+		hookCore,
 	)
 
 	// 构建最终的 logger
@@ -158,17 +180,104 @@ func InitPrdLogger(projectName string, config ...*PrdLoggerConfig) {
 		zap.AddCaller(),                   // 添加调用者信息
 		zap.AddCallerSkip(1),              // 跳过一层调用栈，显示实际的调用位置
 		zap.AddStacktrace(zap.ErrorLevel), // Error 及以上级别显示堆栈信息
+		zap.Hooks(metrics.countEntry),     // 按级别统计日志条数
 		// 添加固定前缀字段
 		zap.Fields(
-			zap.String("project", projectName), // 应用名称
+			zap.String(ProjectKey, projectName), // 应用名称
 		),
 	)
 
 	// 替换全局的 logger
 	zap.ReplaceGlobals(logger)
+	replaceHookDispatcher(dispatcher)
+}
+
+var (
+	hookDispatcherMu  sync.Mutex
+	prdHookDispatcher *hookDispatcher // 当前全局 logger 的钩子 dispatcher
+)
+
+// replaceHookDispatcher 记录新 logger 的 dispatcher，并关闭被替换的 logger 的，避免重复初始化时 goroutine 泄漏
+func replaceHookDispatcher(d *hookDispatcher) {
+	hookDispatcherMu.Lock()
+	old := prdHookDispatcher
+	prdHookDispatcher = d
+	hookDispatcherMu.Unlock()
+	if old != nil {
+		if err := old.close(hookDrainTimeout); err != nil {
+			reportHookErr("dispatcher", err)
+		}
+	}
 }
 
 type PrdLoggerConfig struct {
 	LogDir           string
 	AllProjectLogDir string
+	Hooks            []HookConfig          // 告警钩子，如 Error 及以上级别发送钉钉/飞书通知
+	Registerer       prometheus.Registerer // 传入后注册日志相关的 Prometheus 指标，如 prometheus.DefaultRegisterer
+	ConsoleEncoding  Encoding              // 控制台输出格式，默认 json，kubectl 查看时可用 console 或 logfmt
+	FileEncoding     Encoding              // 项目日志文件（含错误日志）格式，默认 json
+	AllLogEncoding   Encoding              // all.log 格式，默认 json
+	ConsoleLevel     LevelRange            // 控制台输出的级别范围，默认 Info 及以上，如容器日志只需 Warn 及以上
+	AllLogLevel      LevelRange            // all.log 的级别范围，默认 Info 及以上
+}
+
+// LevelRange 日志级别区间，实现 zapcore.LevelEnabler
+// Min 零值即 Info；Max 为 nil 表示不设上限
+// 示例 log.LevelRange{Min: zapcore.WarnLevel}
+type LevelRange struct {
+	Min zapcore.Level
+	Max *zapcore.Level
+}
+
+func (r LevelRange) Enabled(lvl zapcore.Level) bool {
+	return lvl >= r.Min && (r.Max == nil || lvl <= *r.Max)
+}
+
+// Encoding 日志编码格式
+type Encoding string
+
+const (
+	EncodingJSON    Encoding = "json"    // JSON，便于日志收集和解析
+	EncodingConsole Encoding = "console" // zap 自带的控制台格式，字段以 tab 分隔
+	EncodingLogfmt  Encoding = "logfmt"  // key=value 格式
+)
+
+func newEncoder(encoding Encoding, encoderConfig zapcore.EncoderConfig) zapcore.Encoder {
+	switch encoding {
+	case EncodingJSON:
+		return zapcore.NewJSONEncoder(encoderConfig)
+	case EncodingConsole:
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder // 控制台格式下级别用大写更醒目
+		return zapcore.NewConsoleEncoder(encoderConfig)
+	case EncodingLogfmt:
+		return NewLogfmtEncoder(encoderConfig)
+	default:
+		panic("Unknown log encoding: " + string(encoding))
+	}
+}
+
+// DefaultAllProjectLogDir 所有项目共用的 all.log 所在目录
+const DefaultAllProjectLogDir = "/usr/local/yeying/unilogs"
+
+// 生产环境 JSON 日志的字段名，cmd/logq 按这些字段解析日志文件
+const (
+	TimeKey       = "timestamp"
+	LevelKey      = "level"
+	NameKey       = "logger"
+	CallerKey     = "caller"
+	MessageKey    = "msg"
+	StacktraceKey = "stacktrace"
+	ProjectKey    = "project"
+	RequestIdKey  = "request_id"
+	UidKey        = "uid"
+)
+
+// TimeLayout 日志时间格式，东八区 毫秒级
+const TimeLayout = "2006-01-02T15:04:05.000+08:00"
+
+var cstZone = time.FixedZone("CST", 8*3600)
+
+func encodeCSTTime(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(t.In(cstZone).Format(TimeLayout))
 }
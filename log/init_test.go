@@ -0,0 +1,36 @@
+package log
+
+import (
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLevelRange(t *testing.T) {
+	errLvl := zapcore.ErrorLevel
+	infoLvl := zapcore.InfoLevel
+	tests := []struct {
+		name string
+		r    LevelRange
+		lvl  zapcore.Level
+		want bool
+	}{
+		{"zero value accepts info", LevelRange{}, zapcore.InfoLevel, true},
+		{"zero value accepts fatal", LevelRange{}, zapcore.FatalLevel, true},
+		{"zero value rejects debug", LevelRange{}, zapcore.DebugLevel, false},
+		{"min warn rejects info", LevelRange{Min: zapcore.WarnLevel}, zapcore.InfoLevel, false},
+		{"min warn accepts error", LevelRange{Min: zapcore.WarnLevel}, zapcore.ErrorLevel, true},
+		{"debug min accepts debug", LevelRange{Min: zapcore.DebugLevel}, zapcore.DebugLevel, true},
+		{"max error accepts error", LevelRange{Max: &errLvl}, zapcore.ErrorLevel, true},
+		{"max error rejects fatal", LevelRange{Max: &errLvl}, zapcore.FatalLevel, false},
+		{"info only accepts info", LevelRange{Min: zapcore.InfoLevel, Max: &infoLvl}, zapcore.InfoLevel, true},
+		{"info only rejects warn", LevelRange{Min: zapcore.InfoLevel, Max: &infoLvl}, zapcore.WarnLevel, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.Enabled(tt.lvl); got != tt.want {
+				t.Errorf("Enabled(%s) = %v, want %v", tt.lvl, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package log
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var logfmtPool = buffer.NewPool()
+
+// logfmtEncoder 输出 logfmt 格式（key=value 以空格分隔），zap 本身不提供
+// 嵌套的数组/对象会编码为 JSON 字符串作为 value
+type logfmtEncoder struct {
+	cfg        *zapcore.EncoderConfig
+	buf        *buffer.Buffer
+	namespaces []string
+}
+
+// NewLogfmtEncoder 创建 logfmt 编码器，EncoderConfig 的各字段含义与 JSON 编码器一致
+func NewLogfmtEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &logfmtEncoder{cfg: &cfg, buf: logfmtPool.Get()}
+}
+
+func (e *logfmtEncoder) Clone() zapcore.Encoder {
+	clone := &logfmtEncoder{
+		cfg:        e.cfg,
+		buf:        logfmtPool.Get(),
+		namespaces: append([]string(nil), e.namespaces...),
+	}
+	clone.buf.Write(e.buf.Bytes())
+	return clone
+}
+
+func (e *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := &logfmtEncoder{cfg: e.cfg, buf: logfmtPool.Get()}
+
+	if e.cfg.TimeKey != "" {
+		if e.cfg.EncodeTime != nil {
+			final.addEncoded(e.cfg.TimeKey, func(enc zapcore.PrimitiveArrayEncoder) { e.cfg.EncodeTime(ent.Time, enc) })
+		} else {
+			final.AddTime(e.cfg.TimeKey, ent.Time)
+		}
+	}
+	if e.cfg.LevelKey != "" {
+		if e.cfg.EncodeLevel != nil {
+			final.addEncoded(e.cfg.LevelKey, func(enc zapcore.PrimitiveArrayEncoder) { e.cfg.EncodeLevel(ent.Level, enc) })
+		} else {
+			final.AddString(e.cfg.LevelKey, ent.Level.String())
+		}
+	}
+	if e.cfg.NameKey != "" && ent.LoggerName != "" {
+		if e.cfg.EncodeName != nil {
+			final.addEncoded(e.cfg.NameKey, func(enc zapcore.PrimitiveArrayEncoder) { e.cfg.EncodeName(ent.LoggerName, enc) })
+		} else {
+			final.AddString(e.cfg.NameKey, ent.LoggerName)
+		}
+	}
+	if ent.Caller.Defined {
+		if e.cfg.CallerKey != "" {
+			if e.cfg.EncodeCaller != nil {
+				final.addEncoded(e.cfg.CallerKey, func(enc zapcore.PrimitiveArrayEncoder) { e.cfg.EncodeCaller(ent.Caller, enc) })
+			} else {
+				final.AddString(e.cfg.CallerKey, ent.Caller.String())
+			}
+		}
+		if e.cfg.FunctionKey != "" {
+			final.AddString(e.cfg.FunctionKey, ent.Caller.Function)
+		}
+	}
+	if e.cfg.MessageKey != "" {
+		final.AddString(e.cfg.MessageKey, ent.Message)
+	}
+
+	// logger 上的固定字段
+	if e.buf.Len() > 0 {
+		if final.buf.Len() > 0 {
+			final.buf.AppendByte(' ')
+		}
+		final.buf.Write(e.buf.Bytes())
+	}
+	final.namespaces = append([]string(nil), e.namespaces...)
+	for _, f := range fields {
+		f.AddTo(final)
+	}
+	final.namespaces = nil
+
+	if ent.Stack != "" && e.cfg.StacktraceKey != "" {
+		final.AddString(e.cfg.StacktraceKey, ent.Stack)
+	}
+
+	if e.cfg.LineEnding != "" {
+		final.buf.AppendString(e.cfg.LineEnding)
+	} else {
+		final.buf.AppendString(zapcore.DefaultLineEnding)
+	}
+	return final.buf, nil
+}
+
+func (e *logfmtEncoder) addKey(key string) {
+	if e.buf.Len() > 0 {
+		e.buf.AppendByte(' ')
+	}
+	for _, ns := range e.namespaces {
+		e.buf.AppendString(sanitizeLogfmtKey(ns))
+		e.buf.AppendByte('.')
+	}
+	e.buf.AppendString(sanitizeLogfmtKey(key))
+	e.buf.AppendByte('=')
+}
+
+func (e *logfmtEncoder) appendValue(v string) {
+	if needsLogfmtQuote(v) {
+		e.buf.AppendString(strconv.Quote(v))
+		return
+	}
+	e.buf.AppendString(v)
+}
+
+// addEncoded 调用 EncoderConfig 中的 EncodeTime、EncodeLevel 等函数，把结果作为一个 value
+func (e *logfmtEncoder) addEncoded(key string, encode func(zapcore.PrimitiveArrayEncoder)) {
+	var vc valueCapture
+	encode(&vc)
+	e.addKey(key)
+	e.appendValue(strings.Join(vc.parts, " "))
+}
+
+func (e *logfmtEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := m.AddArray(key, arr); err != nil {
+		return err
+	}
+	return e.AddReflected(key, m.Fields[key])
+}
+
+func (e *logfmtEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := obj.MarshalLogObject(m); err != nil {
+		return err
+	}
+	return e.AddReflected(key, m.Fields)
+}
+
+func (e *logfmtEncoder) AddBinary(key string, val []byte) {
+	e.AddString(key, base64.StdEncoding.EncodeToString(val))
+}
+
+func (e *logfmtEncoder) AddByteString(key string, val []byte) {
+	e.AddString(key, string(val))
+}
+
+func (e *logfmtEncoder) AddBool(key string, val bool) {
+	e.addKey(key)
+	e.buf.AppendBool(val)
+}
+
+func (e *logfmtEncoder) AddComplex128(key string, val complex128) {
+	e.addKey(key)
+	e.appendValue(fmt.Sprint(val))
+}
+
+func (e *logfmtEncoder) AddComplex64(key string, val complex64) {
+	e.AddComplex128(key, complex128(val))
+}
+
+func (e *logfmtEncoder) AddDuration(key string, val time.Duration) {
+	if e.cfg.EncodeDuration != nil {
+		e.addEncoded(key, func(enc zapcore.PrimitiveArrayEncoder) { e.cfg.EncodeDuration(val, enc) })
+		return
+	}
+	e.AddString(key, val.String())
+}
+
+func (e *logfmtEncoder) AddFloat64(key string, val float64) {
+	e.addKey(key)
+	e.buf.AppendFloat(val, 64)
+}
+
+func (e *logfmtEncoder) AddFloat32(key string, val float32) {
+	e.addKey(key)
+	e.buf.AppendFloat(float64(val), 32)
+}
+
+func (e *logfmtEncoder) AddInt(key string, val int)     { e.AddInt64(key, int64(val)) }
+func (e *logfmtEncoder) AddInt32(key string, val int32) { e.AddInt64(key, int64(val)) }
+func (e *logfmtEncoder) AddInt16(key string, val int16) { e.AddInt64(key, int64(val)) }
+func (e *logfmtEncoder) AddInt8(key string, val int8)   { e.AddInt64(key, int64(val)) }
+
+func (e *logfmtEncoder) AddInt64(key string, val int64) {
+	e.addKey(key)
+	e.buf.AppendInt(val)
+}
+
+func (e *logfmtEncoder) AddString(key, val string) {
+	e.addKey(key)
+	e.appendValue(val)
+}
+
+func (e *logfmtEncoder) AddTime(key string, val time.Time) {
+	if e.cfg.EncodeTime != nil {
+		e.addEncoded(key, func(enc zapcore.PrimitiveArrayEncoder) { e.cfg.EncodeTime(val, enc) })
+		return
+	}
+	e.AddString(key, val.Format(time.RFC3339Nano))
+}
+
+func (e *logfmtEncoder) AddUint(key string, val uint)       { e.AddUint64(key, uint64(val)) }
+func (e *logfmtEncoder) AddUint32(key string, val uint32)   { e.AddUint64(key, uint64(val)) }
+func (e *logfmtEncoder) AddUint16(key string, val uint16)   { e.AddUint64(key, uint64(val)) }
+func (e *logfmtEncoder) AddUint8(key string, val uint8)     { e.AddUint64(key, uint64(val)) }
+func (e *logfmtEncoder) AddUintptr(key string, val uintptr) { e.AddUint64(key, uint64(val)) }
+
+func (e *logfmtEncoder) AddUint64(key string, val uint64) {
+	e.addKey(key)
+	e.buf.AppendUint(val)
+}
+
+func (e *logfmtEncoder) AddReflected(key string, val interface{}) error {
+	if s, ok := val.(string); ok {
+		e.AddString(key, s)
+		return nil
+	}
+	b, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	e.addKey(key)
+	e.appendValue(string(b))
+	return nil
+}
+
+func (e *logfmtEncoder) OpenNamespace(key string) {
+	e.namespaces = append(e.namespaces, key)
+}
+
+// needsLogfmtQuote 值为空或包含空格、等号、引号、控制字符时需要加引号
+func needsLogfmtQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeLogfmtKey key 中不允许出现空格、等号、引号，替换为下划线
+func sanitizeLogfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// valueCapture 实现 zapcore.PrimitiveArrayEncoder，收集 EncodeTime 等函数输出的值
+type valueCapture struct {
+	parts []string
+}
+
+func (vc *valueCapture) AppendBool(v bool)             { vc.parts = append(vc.parts, strconv.FormatBool(v)) }
+func (vc *valueCapture) AppendByteString(v []byte)     { vc.parts = append(vc.parts, string(v)) }
+func (vc *valueCapture) AppendComplex128(v complex128) { vc.parts = append(vc.parts, fmt.Sprint(v)) }
+func (vc *valueCapture) AppendComplex64(v complex64)   { vc.parts = append(vc.parts, fmt.Sprint(v)) }
+func (vc *valueCapture) AppendFloat64(v float64) {
+	vc.parts = append(vc.parts, strconv.FormatFloat(v, 'g', -1, 64))
+}
+func (vc *valueCapture) AppendFloat32(v float32) {
+	vc.parts = append(vc.parts, strconv.FormatFloat(float64(v), 'g', -1, 32))
+}
+func (vc *valueCapture) AppendInt(v int)       { vc.parts = append(vc.parts, strconv.Itoa(v)) }
+func (vc *valueCapture) AppendInt64(v int64)   { vc.parts = append(vc.parts, strconv.FormatInt(v, 10)) }
+func (vc *valueCapture) AppendInt32(v int32)   { vc.AppendInt64(int64(v)) }
+func (vc *valueCapture) AppendInt16(v int16)   { vc.AppendInt64(int64(v)) }
+func (vc *valueCapture) AppendInt8(v int8)     { vc.AppendInt64(int64(v)) }
+func (vc *valueCapture) AppendString(v string) { vc.parts = append(vc.parts, v) }
+func (vc *valueCapture) AppendUint(v uint)     { vc.AppendUint64(uint64(v)) }
+func (vc *valueCapture) AppendUint64(v uint64) {
+	vc.parts = append(vc.parts, strconv.FormatUint(v, 10))
+}
+func (vc *valueCapture) AppendUint32(v uint32)   { vc.AppendUint64(uint64(v)) }
+func (vc *valueCapture) AppendUint16(v uint16)   { vc.AppendUint64(uint64(v)) }
+func (vc *valueCapture) AppendUint8(v uint8)     { vc.AppendUint64(uint64(v)) }
+func (vc *valueCapture) AppendUintptr(v uintptr) { vc.AppendUint64(uint64(v)) }
@@ -0,0 +1,100 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogfmtEncoder(t *testing.T) {
+	cfg := zapcore.EncoderConfig{
+		TimeKey:        TimeKey,
+		LevelKey:       LevelKey,
+		NameKey:        NameKey,
+		CallerKey:      CallerKey,
+		FunctionKey:    zapcore.OmitKey,
+		MessageKey:     MessageKey,
+		StacktraceKey:  StacktraceKey,
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     encodeCSTTime,
+		EncodeDuration: zapcore.SecondsDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+	entry := zapcore.Entry{
+		Level:   zapcore.InfoLevel,
+		Time:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Message: "下单成功",
+	}
+	prefix := "timestamp=2024-05-01T20:00:00.000+08:00 level=info msg=下单成功"
+
+	tests := []struct {
+		name   string
+		with   []zapcore.Field // logger 上的固定字段
+		fields []zapcore.Field
+		want   string
+	}{
+		{
+			name:   "plain values",
+			fields: []zapcore.Field{zap.Int("order_id", 42), zap.Bool("paid", true), zap.Duration("cost", 1500*time.Millisecond)},
+			want:   prefix + " order_id=42 paid=true cost=1.5\n",
+		},
+		{
+			name:   "quotes values with spaces, equals, quotes and empty",
+			fields: []zapcore.Field{zap.String("a", "x y"), zap.String("b", "k=v"), zap.String("c", `say "hi"`), zap.String("d", "")},
+			want:   prefix + ` a="x y" b="k=v" c="say \"hi\"" d=""` + "\n",
+		},
+		{
+			name:   "escapes control characters",
+			fields: []zapcore.Field{zap.String("err", "line1\nline2")},
+			want:   prefix + ` err="line1\nline2"` + "\n",
+		},
+		{
+			name:   "sanitizes keys",
+			fields: []zapcore.Field{zap.String("bad key=", "v")},
+			want:   prefix + " bad_key_=v\n",
+		},
+		{
+			name:   "logger fields come before entry fields",
+			with:   []zapcore.Field{zap.String(ProjectKey, "user_srv")},
+			fields: []zapcore.Field{zap.String("uid", "u1")},
+			want:   prefix + " project=user_srv uid=u1\n",
+		},
+		{
+			name:   "namespaces prefix keys",
+			fields: []zapcore.Field{zap.Namespace("req"), zap.String("path", "/v1")},
+			want:   prefix + " req.path=/v1\n",
+		},
+		{
+			name:   "nested values are encoded as json",
+			fields: []zapcore.Field{zap.Strings("tags", []string{"a", "b"}), zap.Any("m", map[string]int{"k": 1})},
+			want:   prefix + ` tags="[\"a\",\"b\"]" m="{\"k\":1}"` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := NewLogfmtEncoder(cfg)
+			for _, f := range tt.with {
+				f.AddTo(enc)
+			}
+			buf, err := enc.EncodeEntry(entry, tt.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewEncoderUnknown(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("newEncoder with unknown encoding should panic")
+		}
+	}()
+	newEncoder("xml", zapcore.EncoderConfig{})
+}
@@ -0,0 +1,148 @@
+package log
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// 日志输出位置，用作指标的 sink 标签
+const (
+	sinkApp     = "app"
+	sinkError   = "error"
+	sinkConsole = "console"
+	sinkAll     = "all"
+)
+
+// logMetrics 日志子系统的 Prometheus 指标，为 nil 时所有方法都是空操作
+type logMetrics struct {
+	project      string
+	entries      *prometheus.CounterVec // 按级别统计的日志条数
+	bytes        *prometheus.CounterVec // 每个 sink 写入的字节数
+	rotations    *prometheus.CounterVec // 每个 sink 的日志轮转次数
+	writeErrors  *prometheus.CounterVec // 每个 sink 的写入失败次数
+	dropped      *prometheus.CounterVec // 被丢弃的日志/告警条数
+	hookFailures *prometheus.CounterVec // 告警钩子失败次数
+}
+
+// newLogMetrics 在调用方传入的 Registerer 上注册指标
+// 重复调用 InitPrdLogger 时复用已注册的指标，不会 panic
+func newLogMetrics(reg prometheus.Registerer, project string) *logMetrics {
+	if reg == nil {
+		return nil
+	}
+	return &logMetrics{
+		project: project,
+		entries: registerCounterVec(reg, prometheus.CounterOpts{
+			Name: "log_entries_total",
+			Help: "Number of log entries written, by project and level.",
+		}, "project", "level"),
+		bytes: registerCounterVec(reg, prometheus.CounterOpts{
+			Name: "log_bytes_written_total",
+			Help: "Number of bytes written to each log sink.",
+		}, "project", "sink"),
+		rotations: registerCounterVec(reg, prometheus.CounterOpts{
+			Name: "log_rotations_total",
+			Help: "Number of log file rotations observed, by sink.",
+		}, "project", "sink"),
+		writeErrors: registerCounterVec(reg, prometheus.CounterOpts{
+			Name: "log_write_errors_total",
+			Help: "Number of failed writes to each log sink.",
+		}, "project", "sink"),
+		dropped: registerCounterVec(reg, prometheus.CounterOpts{
+			Name: "log_dropped_entries_total",
+			Help: "Number of log entries dropped, by reason.",
+		}, "project", "reason"),
+		hookFailures: registerCounterVec(reg, prometheus.CounterOpts{
+			Name: "log_hook_failures_total",
+			Help: "Number of failed log hook invocations, by hook name.",
+		}, "project", "hook"),
+	}
+}
+
+func registerCounterVec(reg prometheus.Registerer, opts prometheus.CounterOpts, labels ...string) *prometheus.CounterVec {
+	c := prometheus.NewCounterVec(opts, labels)
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(*prometheus.CounterVec); ok {
+				return existing
+			}
+		}
+		panic("Failed to register log metrics: " + err.Error())
+	}
+	return c
+}
+
+// countEntry 用作 zap.Hooks，每条真正写出的日志计数一次
+func (m *logMetrics) countEntry(ent zapcore.Entry) error {
+	if m != nil {
+		m.entries.WithLabelValues(m.project, ent.Level.String()).Inc()
+	}
+	return nil
+}
+
+func (m *logMetrics) incDropped(reason string) {
+	if m != nil {
+		m.dropped.WithLabelValues(m.project, reason).Inc()
+	}
+}
+
+func (m *logMetrics) incHookFailure(hook string) {
+	if m != nil {
+		m.hookFailures.WithLabelValues(m.project, hook).Inc()
+	}
+}
+
+// meteredWriter 统计写入字节数和写入失败次数
+// 对 lumberjack 按相同规则估算轮转：当前大小 + 本次写入 > MaxSize 时 lumberjack 会轮转
+// 注意 all.log 由多个进程共同写入，只能统计到本进程触发的轮转
+type meteredWriter struct {
+	w    zapcore.WriteSyncer
+	sink string
+	m    *logMetrics
+
+	mu      sync.Mutex
+	size    int64
+	maxSize int64 // 为 0 表示不统计轮转
+}
+
+func newMeteredWriter(w zapcore.WriteSyncer, sink string, m *logMetrics, lj *lumberjack.Logger) zapcore.WriteSyncer {
+	if m == nil {
+		return w
+	}
+	mw := &meteredWriter{w: w, sink: sink, m: m}
+	if lj != nil {
+		mw.maxSize = int64(lj.MaxSize) * 1024 * 1024
+		if info, err := os.Stat(lj.Filename); err == nil {
+			mw.size = info.Size()
+		}
+	}
+	return mw
+}
+
+func (mw *meteredWriter) Write(p []byte) (int, error) {
+	if mw.maxSize > 0 {
+		mw.mu.Lock()
+		if mw.size+int64(len(p)) > mw.maxSize {
+			mw.m.rotations.WithLabelValues(mw.m.project, mw.sink).Inc()
+			mw.size = 0
+		}
+		mw.size += int64(len(p))
+		mw.mu.Unlock()
+	}
+	n, err := mw.w.Write(p)
+	mw.m.bytes.WithLabelValues(mw.m.project, mw.sink).Add(float64(n))
+	if err != nil {
+		mw.m.writeErrors.WithLabelValues(mw.m.project, mw.sink).Inc()
+	}
+	return n, err
+}
+
+func (mw *meteredWriter) Sync() error {
+	return mw.w.Sync()
+}
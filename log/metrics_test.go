@@ -0,0 +1,133 @@
+package log
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// counterValue 从 registry 中读取指定标签的 counter 值，不存在时返回 0
+func counterValue(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+	next:
+		for _, m := range f.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if want, ok := labels[lp.GetName()]; ok && want != lp.GetValue() {
+					continue next
+				}
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+type failingSyncer struct {
+	err error
+}
+
+func (f *failingSyncer) Write(p []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	return len(p), nil
+}
+
+func (f *failingSyncer) Sync() error { return nil }
+
+func TestLogMetricsEntries(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := newLogMetrics(reg, "demo")
+	// 重复注册复用已有指标，不 panic
+	again := newLogMetrics(reg, "demo")
+
+	for _, lvl := range []zapcore.Level{zapcore.InfoLevel, zapcore.InfoLevel, zapcore.ErrorLevel} {
+		_ = m.countEntry(zapcore.Entry{Level: lvl})
+	}
+	_ = again.countEntry(zapcore.Entry{Level: zapcore.ErrorLevel})
+	m.incDropped("hook_queue_full")
+	m.incHookFailure("webhook")
+
+	tests := []struct {
+		name   string
+		metric string
+		labels map[string]string
+		want   float64
+	}{
+		{"info entries", "log_entries_total", map[string]string{"project": "demo", "level": "info"}, 2},
+		{"error entries", "log_entries_total", map[string]string{"project": "demo", "level": "error"}, 2},
+		{"no warn entries", "log_entries_total", map[string]string{"project": "demo", "level": "warn"}, 0},
+		{"dropped", "log_dropped_entries_total", map[string]string{"reason": "hook_queue_full"}, 1},
+		{"hook failures", "log_hook_failures_total", map[string]string{"hook": "webhook"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := counterValue(t, reg, tt.metric, tt.labels); got != tt.want {
+				t.Errorf("%s%v = %v, want %v", tt.metric, tt.labels, got, tt.want)
+			}
+		})
+	}
+
+	// 未传入 Registerer 时所有方法都是空操作
+	var nilMetrics *logMetrics
+	_ = nilMetrics.countEntry(zapcore.Entry{})
+	nilMetrics.incDropped("x")
+	nilMetrics.incHookFailure("x")
+}
+
+func TestMeteredWriter(t *testing.T) {
+	tests := []struct {
+		name          string
+		writeErr      error
+		maxSize       int // lumberjack MaxSize，单位 MB，0 表示不是文件
+		writes        []int
+		wantBytes     float64
+		wantRotations float64
+		wantErrors    float64
+	}{
+		{name: "counts bytes", writes: []int{10, 20}, wantBytes: 30},
+		{name: "rotation estimated when exceeding max size", maxSize: 1, writes: []int{400 << 10, 400 << 10, 400 << 10}, wantBytes: 1200 << 10, wantRotations: 1},
+		{name: "write errors", writeErr: errors.New("disk full"), writes: []int{10, 10}, wantErrors: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			m := newLogMetrics(reg, "demo")
+			var lj *lumberjack.Logger
+			if tt.maxSize > 0 {
+				lj = &lumberjack.Logger{Filename: t.TempDir() + "/demo.log", MaxSize: tt.maxSize}
+			}
+			w := newMeteredWriter(&failingSyncer{err: tt.writeErr}, sinkApp, m, lj)
+			for _, n := range tt.writes {
+				_, _ = w.Write(make([]byte, n))
+			}
+			labels := map[string]string{"sink": sinkApp}
+			if got := counterValue(t, reg, "log_bytes_written_total", labels); got != tt.wantBytes {
+				t.Errorf("bytes = %v, want %v", got, tt.wantBytes)
+			}
+			if got := counterValue(t, reg, "log_rotations_total", labels); got != tt.wantRotations {
+				t.Errorf("rotations = %v, want %v", got, tt.wantRotations)
+			}
+			if got := counterValue(t, reg, "log_write_errors_total", labels); got != tt.wantErrors {
+				t.Errorf("write errors = %v, want %v", got, tt.wantErrors)
+			}
+		})
+	}
+
+	// 未开启指标时原样返回
+	raw := &failingSyncer{}
+	if w := newMeteredWriter(raw, sinkApp, nil, nil); w != raw {
+		t.Error("newMeteredWriter with nil metrics should return the writer unchanged")
+	}
+}
@@ -0,0 +1,95 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WebhookFormat webhook 消息体格式
+type WebhookFormat string
+
+const (
+	WebhookDingTalk WebhookFormat = "dingtalk" // {"msgtype":"text","text":{"content":"..."}}
+	WebhookFeishu   WebhookFormat = "feishu"   // {"msg_type":"text","content":{"text":"..."}}
+	WebhookSlack    WebhookFormat = "slack"    // {"text":"..."}
+)
+
+// WebhookHook 以 HTTP POST 发送告警，兼容钉钉、飞书、Slack 机器人的 JSON 格式
+type WebhookHook struct {
+	URL    string
+	Format WebhookFormat
+	Client *http.Client // 为空时使用 3 秒超时的默认 client，测试时可传 httptest.Server.Client()
+}
+
+// NewWebhookHook 创建 webhook 钩子
+// 示例 log.NewWebhookHook("https://oapi.dingtalk.com/robot/send?access_token=xxx", log.WebhookDingTalk)
+func NewWebhookHook(url string, format WebhookFormat) *WebhookHook {
+	return &WebhookHook{
+		URL:    url,
+		Format: format,
+		Client: &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+func (w *WebhookHook) Fire(entry *HookEntry) error {
+	body, err := json.Marshal(w.payload(formatHookText(entry)))
+	if err != nil {
+		return err
+	}
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 3 * time.Second}
+	}
+	resp, err := client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook responded %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+func (w *WebhookHook) payload(text string) interface{} {
+	switch w.Format {
+	case WebhookDingTalk:
+		return map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]string{"content": text},
+		}
+	case WebhookFeishu:
+		return map[string]interface{}{
+			"msg_type": "text",
+			"content":  map[string]string{"text": text},
+		}
+	default:
+		return map[string]string{"text": text}
+	}
+}
+
+// formatHookText 拼出告警正文，字段按 key 排序，方便阅读
+func formatHookText(entry *HookEntry) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s] %s", strings.ToUpper(entry.Level.String()), entry.Message)
+	sb.WriteString("\ntime: " + entry.Time.In(cstZone).Format(TimeLayout))
+	if entry.Caller.Defined {
+		sb.WriteString("\ncaller: " + entry.Caller.TrimmedPath())
+	}
+	keys := make([]string, 0, len(entry.Fields))
+	for k := range entry.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "\n%s: %v", k, entry.Fields[k])
+	}
+	return sb.String()
+}
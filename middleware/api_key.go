@@ -0,0 +1,231 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccnj/go-utils/log"
+	"github.com/ccnj/go-utils/passhash"
+	"github.com/ccnj/go-utils/rand/cryptorand"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	apiKeyIDLength     = 8  // key id 中随机部分的长度
+	apiKeySecretLength = 32 // 密钥部分的长度
+	apiKeySaltLength   = 16
+)
+
+var ErrAPIKeyNotFound = errors.New("middleware: api key not found")
+
+// APIKey 存储中的一条 API key 记录，明文 key 只在生成时返回一次，存储中只保存哈希
+// 明文格式为 "<ID>_<密钥>"，如 "ak_7F3K9Q2M_0RZE1QEUXP79Y4O7JZUDXTVRB2R2SI4A"
+type APIKey struct {
+	ID         string    // 明文前缀，如 "ak_7F3K9Q2M"，用于查找，也可在管理页面展示以便辨认
+	Hash       string    // passhash.HashPassword 得到的哈希
+	Salt       string    // 哈希使用的盐
+	Name       string    // 备注，如合作方名称
+	UID        string    // 认证通过后写入 Principal 的 uid
+	Role       int32     // 认证通过后写入 Principal 的 role
+	Scopes     []string  // 授权范围，配合 RequireScopes 使用
+	CreatedAt  time.Time // 生成时间
+	ExpiresAt  time.Time // 零值表示不过期
+	LastUsedAt time.Time // 最近一次使用时间，由中间件更新
+}
+
+// APIKeyStore API key 的存储，生产环境可用 MySQL、Redis 实现
+type APIKeyStore interface {
+	Save(ctx context.Context, key *APIKey) error
+	// Get 不存在时返回 nil, nil
+	Get(ctx context.Context, id string) (*APIKey, error)
+	// TouchLastUsed 更新最近使用时间
+	TouchLastUsed(ctx context.Context, id string, at time.Time) error
+	// Delete 吊销 key，不存在时返回 ErrAPIKeyNotFound
+	Delete(ctx context.Context, id string) error
+}
+
+// GenerateAPIKey 生成 API key，填充 key 的 ID、Hash、Salt、CreatedAt，返回只展示一次的明文 key
+// prefix 用于区分用途或环境，如 "ak"、"sk_live"，不能为空
+// 示例
+//
+//	key := &middleware.APIKey{Name: "合作方A", UID: "10086", Scopes: []string{"order:read"}}
+//	plain, err := middleware.GenerateAPIKey("ak", key)
+//	err = store.Save(ctx, key)
+func GenerateAPIKey(prefix string, key *APIKey) (string, error) {
+	if prefix == "" {
+		return "", errors.New("middleware: api key prefix is required")
+	}
+	id, err := cryptorand.GenCryptoRandStr(apiKeyIDLength)
+	if err != nil {
+		return "", err
+	}
+	secret, err := cryptorand.GenCryptoRandStr(apiKeySecretLength)
+	if err != nil {
+		return "", err
+	}
+	key.ID = prefix + "_" + id
+	plain := key.ID + "_" + secret
+	salt, hash, err := passhash.EasyHash(plain, apiKeySaltLength)
+	if err != nil {
+		return "", err
+	}
+	key.Salt, key.Hash = salt, hash
+	key.CreatedAt = time.Now()
+	return plain, nil
+}
+
+// parseAPIKeyID 从明文 key 中取出 ID，即最后一个 _ 之前的部分
+func parseAPIKeyID(plain string) (string, bool) {
+	i := strings.LastIndexByte(plain, '_')
+	if i <= 0 || len(plain)-i-1 != apiKeySecretLength {
+		return "", false
+	}
+	return plain[:i], true
+}
+
+// APIKeyConfig APIKeyAuth 的配置
+type APIKeyConfig struct {
+	Store APIKeyStore // 必填，测试可用 NewMemoryAPIKeyStore()
+	// Extractors 读取 key 的位置，为空时依次读取 "X-API-Key" 请求头和 "Authorization: ApiKey ..."
+	Extractors []TokenExtractor
+	Skip       *RouteMatcher // 不需要验证的路由
+	// TouchInterval 最近使用时间的最小更新间隔，避免每个请求都写存储，默认 1 分钟
+	TouchInterval time.Duration
+	// Errors 失败响应的配置，为空时使用 DefaultAuthErrors，WWW-Authenticate 的认证方案为 ApiKey
+	Errors *AuthErrorConfig
+}
+
+// APIKeyAuth 供服务端调用方使用的 API key 认证，通过后与 ValidateToken 一样写入 Principal、uid、role
+// 示例 partner := r.Group("/open", middleware.APIKeyAuth(&middleware.APIKeyConfig{Store: store}), middleware.RequireScopes("order:read"))
+func APIKeyAuth(config *APIKeyConfig) gin.HandlerFunc {
+	if config.Store == nil {
+		panic("middleware: APIKeyConfig.Store is required")
+	}
+	store := config.Store
+	extractors := config.Extractors
+	if len(extractors) == 0 {
+		extractors = []TokenExtractor{FromHeader("X-API-Key"), FromAuthHeader("Authorization", "ApiKey")}
+	}
+	touchInterval := config.TouchInterval
+	if touchInterval <= 0 {
+		touchInterval = time.Minute
+	}
+	errs := config.Errors
+	if errs == nil {
+		defaults := *DefaultAuthErrors
+		if defaults.Scheme == "" {
+			defaults.Scheme = "ApiKey"
+		}
+		errs = &defaults
+	}
+
+	return func(ctx *gin.Context) {
+		if _, ok := config.Skip.Match(ctx); ok {
+			ctx.Set("uid", "")
+			return
+		}
+
+		plain, err := extractToken(ctx, extractors)
+		if err != nil {
+			errs.abort(ctx, FailureMalformedHeader, err)
+			return
+		}
+		if plain == "" {
+			errs.abort(ctx, FailureMissingToken, nil)
+			return
+		}
+		id, ok := parseAPIKeyID(plain)
+		if !ok {
+			errs.abort(ctx, FailureMalformedToken, nil)
+			return
+		}
+
+		key, err := store.Get(ctx, id)
+		if err != nil {
+			// 存储故障不是调用方的问题，返回 500 以便重试，而不是让其误以为 key 无效
+			log.Error(ctx, "查询 API key 失败", "key_id", id, "err", err)
+			errs.abort(ctx, FailureUnavailable, err)
+			return
+		}
+		if key == nil || !passhash.Verify(plain, key.Hash, key.Salt) {
+			log.Info(ctx, "API key 验证失败", "key_id", id)
+			errs.abort(ctx, FailureInvalidToken, nil)
+			return
+		}
+		now := time.Now()
+		if !key.ExpiresAt.IsZero() && now.After(key.ExpiresAt) {
+			errs.abort(ctx, FailureExpiredToken, nil)
+			return
+		}
+		if now.Sub(key.LastUsedAt) >= touchInterval {
+			// 更新失败不影响本次请求
+			if err := store.TouchLastUsed(ctx, key.ID, now); err != nil {
+				log.Warn(ctx, "更新 API key 使用时间失败", "key_id", key.ID, "err", err)
+			}
+		}
+
+		SetPrincipal(ctx, &Principal{
+			UID:    key.UID,
+			Role:   key.Role,
+			Scopes: key.Scopes,
+			KeyID:  key.ID,
+		})
+	}
+}
+
+// MemoryAPIKeyStore 内存实现的 APIKeyStore，用于测试和单实例服务
+type MemoryAPIKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]*APIKey
+}
+
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{keys: make(map[string]*APIKey)}
+}
+
+func (s *MemoryAPIKeyStore) Save(ctx context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := *key
+	k.Scopes = append([]string(nil), key.Scopes...)
+	s.keys[k.ID] = &k
+	return nil
+}
+
+func (s *MemoryAPIKeyStore) Get(ctx context.Context, id string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *k
+	copied.Scopes = append([]string(nil), k.Scopes...)
+	return &copied, nil
+}
+
+func (s *MemoryAPIKeyStore) TouchLastUsed(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	if at.After(k.LastUsedAt) {
+		k.LastUsedAt = at
+	}
+	return nil
+}
+
+func (s *MemoryAPIKeyStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[id]; !ok {
+		return ErrAPIKeyNotFound
+	}
+	delete(s.keys, id)
+	return nil
+}
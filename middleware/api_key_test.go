@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ccnj/go-utils/passhash"
+)
+
+// brokenAPIKeyStore 模拟存储故障
+type brokenAPIKeyStore struct {
+	*MemoryAPIKeyStore
+}
+
+func (brokenAPIKeyStore) Get(context.Context, string) (*APIKey, error) {
+	return nil, errors.New("mysql: connection refused")
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAPIKeyStore()
+	newKey := func(expiresAt time.Time) string {
+		key := &APIKey{Name: "合作方A", UID: "partner-1", Scopes: []string{"order:read"}, ExpiresAt: expiresAt}
+		plain, err := GenerateAPIKey("ak", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Save(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		return plain
+	}
+	valid := newKey(time.Time{})
+	expired := newKey(time.Now().Add(-time.Hour))
+	// 替换密钥部分，id 存在但哈希不符
+	wrongSecret := valid[:strings.LastIndexByte(valid, '_')+1] + strings.Repeat("A", apiKeySecretLength)
+	unknown := "ak_ZZZZZZZZ_" + strings.Repeat("A", apiKeySecretLength)
+
+	header := func(name, value string) func(*http.Request) {
+		return func(r *http.Request) { r.Header.Set(name, value) }
+	}
+	tests := []struct {
+		name       string
+		store      APIKeyStore
+		path       string
+		setup      func(*http.Request)
+		wantStatus int
+		wantUID    string
+	}{
+		{"x-api-key header", store, "/open/orders", header("X-API-Key", valid), http.StatusOK, "partner-1"},
+		{"authorization scheme", store, "/open/orders", header("Authorization", "ApiKey "+valid), http.StatusOK, "partner-1"},
+		{"missing key", store, "/open/orders", nil, http.StatusUnauthorized, ""},
+		{"wrong scheme", store, "/open/orders", header("Authorization", "Bearer "+valid), http.StatusUnauthorized, ""},
+		{"malformed key", store, "/open/orders", header("X-API-Key", "ak_short"), http.StatusUnauthorized, ""},
+		{"wrong secret", store, "/open/orders", header("X-API-Key", wrongSecret), http.StatusUnauthorized, ""},
+		{"unknown id", store, "/open/orders", header("X-API-Key", unknown), http.StatusUnauthorized, ""},
+		{"expired key", store, "/open/orders", header("X-API-Key", expired), http.StatusUnauthorized, ""},
+		{"store failure", brokenAPIKeyStore{store}, "/open/orders", header("X-API-Key", valid), http.StatusInternalServerError, ""},
+		{"skipped route", store, "/open/ping", nil, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw := APIKeyAuth(&APIKeyConfig{Store: tt.store, Skip: NewRouteMatcher(Exact("/open/ping"))})
+			res := runAuth(t, tt.path, tt.path, tt.setup, mw)
+			if res.status != tt.wantStatus || res.uid != tt.wantUID {
+				t.Fatalf("status = %d, uid = %q, want %d, %q (%s)", res.status, res.uid, tt.wantStatus, tt.wantUID, res.errMsg)
+			}
+			if res.status == http.StatusUnauthorized && !strings.HasPrefix(res.header.Get("WWW-Authenticate"), "ApiKey") {
+				t.Errorf("WWW-Authenticate = %q, want ApiKey scheme", res.header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAPIKeyTouchLastUsed(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAPIKeyStore()
+	key := &APIKey{UID: "partner-1"}
+	plain, err := GenerateAPIKey("ak", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = store.Save(ctx, key)
+	if key.Salt == "" || !passhash.Verify(plain, key.Hash, key.Salt) || strings.Contains(key.Hash, plain) {
+		t.Fatalf("stored hash %q does not match the plain key", key.Hash)
+	}
+
+	mw := APIKeyAuth(&APIKeyConfig{Store: store, TouchInterval: time.Hour})
+	runAuth(t, "/", "/", func(r *http.Request) { r.Header.Set("X-API-Key", plain) }, mw)
+	first, _ := store.Get(ctx, key.ID)
+	if first.LastUsedAt.IsZero() {
+		t.Fatal("LastUsedAt should be updated on first use")
+	}
+	runAuth(t, "/", "/", func(r *http.Request) { r.Header.Set("X-API-Key", plain) }, mw)
+	second, _ := store.Get(ctx, key.ID)
+	if !second.LastUsedAt.Equal(first.LastUsedAt) {
+		t.Error("LastUsedAt should not be updated within TouchInterval")
+	}
+}
+
+func TestParseAPIKeyID(t *testing.T) {
+	secret := strings.Repeat("A", apiKeySecretLength)
+	tests := []struct {
+		plain  string
+		want   string
+		wantOK bool
+	}{
+		{"ak_7F3K9Q2M_" + secret, "ak_7F3K9Q2M", true},
+		{"sk_live_7F3K9Q2M_" + secret, "sk_live_7F3K9Q2M", true},
+		{"ak_7F3K9Q2M_" + secret[1:], "", false},
+		{"_" + secret, "", false},
+		{secret, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := parseAPIKeyID(tt.plain)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseAPIKeyID(%q) = %q, %v, want %q, %v", tt.plain, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
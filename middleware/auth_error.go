@@ -0,0 +1,291 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ccnj/go-utils/token"
+	"github.com/gin-gonic/gin"
+)
+
+// AuthFailure 认证/授权失败的原因
+type AuthFailure string
+
+const (
+	FailureMissingToken     AuthFailure = "missing_token"      // 没有携带 token
+	FailureMalformedHeader  AuthFailure = "malformed_header"   // 授权头格式错误，如缺少 Bearer 前缀
+	FailureMalformedToken   AuthFailure = "malformed_token"    // token 本身无法解析
+	FailureExpiredToken     AuthFailure = "expired_token"      // token 已过期
+	FailureInvalidSignature AuthFailure = "invalid_signature"  // 签名错误
+	FailureRevokedToken     AuthFailure = "revoked_token"      // token 已被吊销
+	FailureInvalidToken     AuthFailure = "invalid_token"      // 其他原因，如 iss、aud 不符
+	FailureForbidden        AuthFailure = "forbidden"          // 已登录但没有权限
+	FailureUnavailable      AuthFailure = "unavailable"        // 吊销记录、API key 等存储不可用，返回 500，不是客户端的问题
+	FailureInvalidCSRFToken AuthFailure = "invalid_csrf_token" // CSRF token 缺失或不匹配，返回 403
+	FailureRequestTooLarge  AuthFailure = "request_too_large"  // 签名请求的请求体超过上限，返回 413
+)
+
+// DefaultAuthErrorCodes 各失败原因默认的 errCode，与 HTTP 状态码一致，兼容按 401、403 处理的老客户端
+var DefaultAuthErrorCodes = map[AuthFailure]int{
+	FailureMissingToken:     401,
+	FailureMalformedHeader:  401,
+	FailureMalformedToken:   401,
+	FailureExpiredToken:     401,
+	FailureInvalidSignature: 401,
+	FailureRevokedToken:     401,
+	FailureInvalidToken:     401,
+	FailureForbidden:        403,
+	FailureUnavailable:      500,
+	FailureInvalidCSRFToken: 403,
+	FailureRequestTooLarge:  413,
+}
+
+// DetailedAuthErrorCodes 细分的 errCode，客户端可据此区分处理，如过期时自动刷新 token
+// 需要时赋给 AuthErrorConfig.Codes 启用
+var DetailedAuthErrorCodes = map[AuthFailure]int{
+	FailureMissingToken:     40101,
+	FailureMalformedHeader:  40102,
+	FailureMalformedToken:   40103,
+	FailureExpiredToken:     40104,
+	FailureInvalidSignature: 40105,
+	FailureRevokedToken:     40106,
+	FailureInvalidToken:     40107,
+	FailureForbidden:        40300,
+	FailureUnavailable:      500,
+	FailureInvalidCSRFToken: 40301,
+	FailureRequestTooLarge:  413,
+}
+
+// MessageCatalog 按语言和失败原因组织的提示语，语言为 BCP 47 标签，如 zh、en、zh-TW
+type MessageCatalog map[string]map[AuthFailure]string
+
+// DefaultAuthMessages 默认提示语
+var DefaultAuthMessages = MessageCatalog{
+	"zh": {
+		FailureMissingToken:     "尚未登录，请先登录～",
+		FailureMalformedHeader:  "授权头格式错误",
+		FailureMalformedToken:   "身份认证失败，请先登录",
+		FailureExpiredToken:     "身份认证已过期，请重新登录",
+		FailureInvalidSignature: "身份认证失败，请先登录",
+		FailureRevokedToken:     "登录状态已失效，请重新登录",
+		FailureInvalidToken:     "身份认证失败，请先登录",
+		FailureForbidden:        "没有权限访问",
+		FailureUnavailable:      "服务繁忙，请稍后再试",
+		FailureInvalidCSRFToken: "页面已过期，请刷新后重试",
+		FailureRequestTooLarge:  "请求体过大",
+	},
+	"en": {
+		FailureMissingToken:     "Please log in first.",
+		FailureMalformedHeader:  "Malformed authorization header.",
+		FailureMalformedToken:   "Malformed access token.",
+		FailureExpiredToken:     "Your session has expired, please log in again.",
+		FailureInvalidSignature: "Authentication failed, please log in again.",
+		FailureRevokedToken:     "Your session is no longer valid, please log in again.",
+		FailureInvalidToken:     "Authentication failed, please log in again.",
+		FailureForbidden:        "You do not have permission to access this resource.",
+		FailureUnavailable:      "Service is busy, please try again later.",
+		FailureInvalidCSRFToken: "The page has expired, please refresh and try again.",
+		FailureRequestTooLarge:  "Request body is too large.",
+	},
+}
+
+// AuthError 一次认证/授权失败，交给 ErrorRenderer 输出
+type AuthError struct {
+	Reason  AuthFailure
+	Status  int    // HTTP 状态码，401、403、413 或 500
+	Code    int    // 响应体中的 errCode
+	Message string // 按 Accept-Language 选择的提示语
+	Err     error  // 底层错误，可能为 nil
+}
+
+func (e *AuthError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Reason, e.Err)
+	}
+	return string(e.Reason)
+}
+
+// ErrorRenderer 自定义失败响应的输出，如统一的响应包装结构，中间件会在其后调用 ctx.Abort
+type ErrorRenderer func(ctx *gin.Context, e *AuthError)
+
+// AuthErrorConfig 认证中间件失败响应的配置，零值即可使用
+type AuthErrorConfig struct {
+	Messages        MessageCatalog      // 提示语，默认 DefaultAuthMessages；缺失的语言或原因回退到默认
+	DefaultLanguage string              // Accept-Language 无法匹配时使用的语言，默认 zh
+	Codes           map[AuthFailure]int // 覆盖部分 errCode，未覆盖的使用 DefaultAuthErrorCodes；可设为 DetailedAuthErrorCodes
+	Realm           string              // WWW-Authenticate 中的 realm，为空则不写
+	Scheme          string              // WWW-Authenticate 中的认证方案，默认 Bearer
+	Renderer        ErrorRenderer       // 为空时输出 {"errCode": ..., "errMsg": ...}
+}
+
+// DefaultAuthErrors 未单独配置时各认证、鉴权中间件使用的配置
+var DefaultAuthErrors = &AuthErrorConfig{}
+
+// abort 输出失败响应并中止后续处理
+func (c *AuthErrorConfig) abort(ctx *gin.Context, reason AuthFailure, err error) {
+	if c == nil {
+		c = DefaultAuthErrors
+	}
+	status := http.StatusUnauthorized
+	switch reason {
+	case FailureForbidden, FailureInvalidCSRFToken:
+		status = http.StatusForbidden
+	case FailureUnavailable:
+		status = http.StatusInternalServerError
+	case FailureRequestTooLarge:
+		status = http.StatusRequestEntityTooLarge
+	}
+	code, ok := c.Codes[reason]
+	if !ok {
+		code = DefaultAuthErrorCodes[reason]
+	}
+	authErr := &AuthError{
+		Reason:  reason,
+		Status:  status,
+		Code:    code,
+		Message: c.message(ctx.GetHeader("Accept-Language"), reason),
+		Err:     err,
+	}
+
+	// 服务端故障、CSRF 校验失败和请求体过大与认证方案无关，不写 WWW-Authenticate
+	if reason != FailureUnavailable && reason != FailureInvalidCSRFToken && reason != FailureRequestTooLarge {
+		ctx.Header("WWW-Authenticate", c.wwwAuthenticate(reason))
+	}
+	if c.Renderer != nil {
+		c.Renderer(ctx, authErr)
+	} else {
+		ctx.JSON(status, gin.H{
+			"errCode": code,
+			"errMsg":  authErr.Message,
+		})
+	}
+	ctx.Abort() // 必须显式地中止。因为gin中，即使没有ctx.Next()，也会在中间件结束时自动执行下一个
+}
+
+// message 按 Accept-Language 的权重依次尝试完整标签和主语言，如 zh-TW -> zh
+func (c *AuthErrorConfig) message(acceptLanguage string, reason AuthFailure) string {
+	catalogs := []MessageCatalog{c.Messages, DefaultAuthMessages}
+	for _, lang := range parseAcceptLanguage(acceptLanguage) {
+		candidates := []string{lang}
+		if i := strings.IndexByte(lang, '-'); i > 0 {
+			candidates = append(candidates, lang[:i])
+		}
+		for _, catalog := range catalogs {
+			for _, cand := range candidates {
+				if msg, ok := lookupMessage(catalog, cand, reason); ok {
+					return msg
+				}
+			}
+		}
+	}
+	defaultLang := c.DefaultLanguage
+	if defaultLang == "" {
+		defaultLang = "zh"
+	}
+	for _, catalog := range catalogs {
+		if msg, ok := lookupMessage(catalog, defaultLang, reason); ok {
+			return msg
+		}
+	}
+	return string(reason)
+}
+
+func lookupMessage(catalog MessageCatalog, lang string, reason AuthFailure) (string, bool) {
+	for l, msgs := range catalog {
+		if strings.EqualFold(l, lang) {
+			msg, ok := msgs[reason]
+			return msg, ok
+		}
+	}
+	return "", false
+}
+
+// parseAcceptLanguage 解析 Accept-Language，按 q 值从高到低返回语言标签，忽略 * 和 q=0
+func parseAcceptLanguage(header string) []string {
+	type langQ struct {
+		lang string
+		q    float64
+	}
+	var langs []langQ
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lang, q := part, 1.0
+		if i := strings.IndexByte(part, ';'); i >= 0 {
+			lang = strings.TrimSpace(part[:i])
+			param := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if lang == "*" || q <= 0 {
+			continue
+		}
+		langs = append(langs, langQ{lang: lang, q: q})
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	result := make([]string, len(langs))
+	for i, l := range langs {
+		result[i] = l.lang
+	}
+	return result
+}
+
+// wwwAuthenticate 按 RFC 6750 第 3 节生成 WWW-Authenticate 响应头
+// 未携带 token 时不带 error 参数
+func (c *AuthErrorConfig) wwwAuthenticate(reason AuthFailure) string {
+	var params []string
+	if c.Realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", c.Realm))
+	}
+	var errCode string
+	switch reason {
+	case FailureMissingToken:
+	case FailureMalformedHeader:
+		errCode = "invalid_request"
+	case FailureForbidden:
+		errCode = "insufficient_scope"
+	default:
+		errCode = "invalid_token"
+	}
+	if errCode != "" {
+		params = append(params, fmt.Sprintf("error=%q", errCode))
+		// error_description 只允许 ASCII，统一用英文提示语
+		if desc, ok := DefaultAuthMessages["en"][reason]; ok {
+			params = append(params, fmt.Sprintf("error_description=%q", desc))
+		}
+	}
+	scheme := c.Scheme
+	if scheme == "" {
+		scheme = "Bearer"
+	}
+	if len(params) == 0 {
+		return scheme
+	}
+	return scheme + " " + strings.Join(params, ", ")
+}
+
+// failureFromVerifyErr 把 token 验证错误映射为失败原因
+func failureFromVerifyErr(err error) AuthFailure {
+	switch token.FailedCheck(err) {
+	case token.CheckMalformed:
+		return FailureMalformedToken
+	case token.CheckExpired:
+		return FailureExpiredToken
+	case token.CheckSignature:
+		return FailureInvalidSignature
+	case token.CheckRevoked:
+		return FailureRevokedToken
+	case token.CheckUnavailable:
+		return FailureUnavailable
+	default:
+		return FailureInvalidToken
+	}
+}
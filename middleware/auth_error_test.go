@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// abortWith 返回一个直接以 reason 失败的中间件
+func abortWith(c *AuthErrorConfig, reason AuthFailure) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		c.abort(ctx, reason, errors.New("test"))
+	}
+}
+
+func withAcceptLanguage(lang string) func(*http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set("Accept-Language", lang)
+	}
+}
+
+func TestAuthErrorCodes(t *testing.T) {
+	detailed := &AuthErrorConfig{Codes: DetailedAuthErrorCodes}
+	override := &AuthErrorConfig{Codes: map[AuthFailure]int{FailureExpiredToken: 1001}}
+	tests := []struct {
+		name       string
+		config     *AuthErrorConfig
+		reason     AuthFailure
+		wantStatus int
+		wantCode   float64
+	}{
+		{"default missing token", nil, FailureMissingToken, http.StatusUnauthorized, 401},
+		{"default expired", nil, FailureExpiredToken, http.StatusUnauthorized, 401},
+		{"default forbidden", nil, FailureForbidden, http.StatusForbidden, 403},
+		{"default unavailable", nil, FailureUnavailable, http.StatusInternalServerError, 500},
+		{"default request too large", nil, FailureRequestTooLarge, http.StatusRequestEntityTooLarge, 413},
+		{"detailed expired", detailed, FailureExpiredToken, http.StatusUnauthorized, 40104},
+		{"detailed forbidden", detailed, FailureForbidden, http.StatusForbidden, 40300},
+		{"override one code", override, FailureExpiredToken, http.StatusUnauthorized, 1001},
+		{"override falls back to default", override, FailureRevokedToken, http.StatusUnauthorized, 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := runAuth(t, "/", "/", nil, abortWith(tt.config, tt.reason))
+			if res.status != tt.wantStatus || res.errCode != tt.wantCode {
+				t.Errorf("status = %d, errCode = %v, want %d, %v", res.status, res.errCode, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestAuthErrorMessage(t *testing.T) {
+	custom := &AuthErrorConfig{
+		DefaultLanguage: "en",
+		Messages: MessageCatalog{
+			"zh-TW": {FailureMissingToken: "尚未登入"},
+			"ja":    {FailureMissingToken: "ログインしてください"},
+		},
+	}
+	tests := []struct {
+		name   string
+		config *AuthErrorConfig
+		lang   string
+		want   string
+	}{
+		{"default language", nil, "", "尚未登录，请先登录～"},
+		{"english", nil, "en-US,en;q=0.9", "Please log in first."},
+		{"q value order", nil, "zh;q=0.5, en;q=0.8", "Please log in first."},
+		{"unknown language falls back", nil, "fr", "尚未登录，请先登录～"},
+		{"custom full tag", custom, "zh-TW", "尚未登入"},
+		{"custom falls back to primary tag", custom, "zh-CN", "尚未登录，请先登录～"},
+		{"custom language", custom, "ja", "ログインしてください"},
+		{"custom default language", custom, "fr", "Please log in first."},
+		{"case insensitive tag", custom, "ZH-tw", "尚未登入"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := runAuth(t, "/", "/", withAcceptLanguage(tt.lang), abortWith(tt.config, FailureMissingToken))
+			if res.errMsg != tt.want {
+				t.Errorf("errMsg = %q, want %q", res.errMsg, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthErrorWWWAuthenticate(t *testing.T) {
+	realm := &AuthErrorConfig{Realm: "api", Scheme: "DPoP"}
+	tests := []struct {
+		name   string
+		config *AuthErrorConfig
+		reason AuthFailure
+		want   string
+	}{
+		{"missing token has no error", nil, FailureMissingToken, "Bearer"},
+		{"malformed header", nil, FailureMalformedHeader, `Bearer error="invalid_request", error_description="Malformed authorization header."`},
+		{"expired", nil, FailureExpiredToken, `Bearer error="invalid_token", error_description="Your session has expired, please log in again."`},
+		{"forbidden", nil, FailureForbidden, `Bearer error="insufficient_scope", error_description="You do not have permission to access this resource."`},
+		{"realm and scheme", realm, FailureMissingToken, `DPoP realm="api"`},
+		{"unavailable has no header", nil, FailureUnavailable, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := runAuth(t, "/", "/", nil, abortWith(tt.config, tt.reason))
+			if got := res.header.Get("WWW-Authenticate"); got != tt.want {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthErrorRenderer(t *testing.T) {
+	var got *AuthError
+	config := &AuthErrorConfig{Renderer: func(ctx *gin.Context, e *AuthError) {
+		got = e
+		ctx.JSON(e.Status, gin.H{"errCode": e.Code, "errMsg": "wrapped: " + e.Message})
+	}}
+	res := runAuth(t, "/", "/", nil, abortWith(config, FailureRevokedToken))
+	if res.status != http.StatusUnauthorized || res.errMsg != "wrapped: 登录状态已失效，请重新登录" {
+		t.Errorf("status = %d, errMsg = %q", res.status, res.errMsg)
+	}
+	if got == nil || got.Reason != FailureRevokedToken || got.Code != 401 || got.Err == nil {
+		t.Errorf("renderer got %+v", got)
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"en", []string{"en"}},
+		{"zh-CN,zh;q=0.9,en;q=0.8", []string{"zh-CN", "zh", "en"}},
+		{"en;q=0.2, ja", []string{"ja", "en"}},
+		{"*, fr;q=0", []string{}},
+		{"de;q=bad", []string{"de"}},
+	}
+	for _, tt := range tests {
+		if got := parseAcceptLanguage(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAcceptLanguage(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// PermissionAll 拥有该权限的角色可以通过任何权限检查，一般分配给超级管理员
+const PermissionAll = "*"
+
+// Policy 角色到权限的映射
+// 示例 middleware.NewPolicy(map[int32][]string{1: {"order:read"}, 9: {middleware.PermissionAll}})
+type Policy struct {
+	permissions map[int32]map[string]bool
+}
+
+func NewPolicy(rolePermissions map[int32][]string) *Policy {
+	p := &Policy{permissions: make(map[int32]map[string]bool, len(rolePermissions))}
+	for role, perms := range rolePermissions {
+		set := make(map[string]bool, len(perms))
+		for _, perm := range perms {
+			set[perm] = true
+		}
+		p.permissions[role] = set
+	}
+	return p
+}
+
+// HasPermission 判断角色是否拥有某个权限
+func (p *Policy) HasPermission(role int32, perm string) bool {
+	set := p.permissions[role]
+	return set[perm] || set[PermissionAll]
+}
+
+// RequireAnyPermission 拥有 perms 中任意一个权限即可访问，可挂在路由组上
+// 示例 admin := r.Group("/admin", middleware.RequireAnyPermission(policy, "user:manage"))
+func (p *Policy) RequireAnyPermission(perms ...string) gin.HandlerFunc {
+	return RequireAnyPermission(p, perms...)
+}
+
+// AuthzConfig RequireRoleWithConfig 等鉴权中间件的配置
+type AuthzConfig struct {
+	// Errors 未登录（401）和无权限（403）时的响应格式，为空使用 DefaultAuthErrors
+	Errors *AuthErrorConfig
+}
+
+// RequireRole 只允许指定角色访问，需放在 ValidateToken 之后
+func RequireRole(roles ...int32) gin.HandlerFunc {
+	return RequireRoleWithConfig(nil, roles...)
+}
+
+// RequireRoleWithConfig 同 RequireRole，config 为 nil 时使用默认配置
+func RequireRoleWithConfig(config *AuthzConfig, roles ...int32) gin.HandlerFunc {
+	errs := config.errors()
+	return func(ctx *gin.Context) {
+		p, ok := requirePrincipal(ctx, errs)
+		if !ok {
+			return
+		}
+		for _, role := range roles {
+			if p.Role == role {
+				return
+			}
+		}
+		errs.abort(ctx, FailureForbidden, nil)
+	}
+}
+
+// RequireAnyPermission 角色拥有 perms 中任意一个权限即可访问，需放在 ValidateToken 之后
+// policy 为 nil 时 panic，避免注册路由时漏配置，到请求时才发现
+func RequireAnyPermission(policy *Policy, perms ...string) gin.HandlerFunc {
+	return RequireAnyPermissionWithConfig(nil, policy, perms...)
+}
+
+// RequireAnyPermissionWithConfig 同 RequireAnyPermission，config 为 nil 时使用默认配置
+func RequireAnyPermissionWithConfig(config *AuthzConfig, policy *Policy, perms ...string) gin.HandlerFunc {
+	if policy == nil {
+		panic("middleware: RequireAnyPermission requires a non-nil *Policy")
+	}
+	errs := config.errors()
+	return func(ctx *gin.Context) {
+		p, ok := requirePrincipal(ctx, errs)
+		if !ok {
+			return
+		}
+		for _, perm := range perms {
+			if policy.HasPermission(p.Role, perm) {
+				return
+			}
+		}
+		errs.abort(ctx, FailureForbidden, nil)
+	}
+}
+
+// RequireScopes token 的授权范围必须包含全部 scopes，需放在 ValidateToken 之后
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return RequireScopesWithConfig(nil, scopes...)
+}
+
+// RequireScopesWithConfig 同 RequireScopes，config 为 nil 时使用默认配置
+func RequireScopesWithConfig(config *AuthzConfig, scopes ...string) gin.HandlerFunc {
+	errs := config.errors()
+	return func(ctx *gin.Context) {
+		p, ok := requirePrincipal(ctx, errs)
+		if !ok {
+			return
+		}
+		granted := make(map[string]bool, len(p.Scopes))
+		for _, s := range p.Scopes {
+			granted[s] = true
+		}
+		for _, s := range scopes {
+			if !granted[s] {
+				errs.abort(ctx, FailureForbidden, nil)
+				return
+			}
+		}
+	}
+}
+
+// errors 返回 nil 时 abort 会在请求时使用 DefaultAuthErrors
+func (c *AuthzConfig) errors() *AuthErrorConfig {
+	if c == nil {
+		return nil
+	}
+	return c.Errors
+}
+
+// requirePrincipal 未登录（如路由被 ValidateToken 跳过）时返回 401
+func requirePrincipal(ctx *gin.Context, errs *AuthErrorConfig) (*Principal, bool) {
+	p, ok := PrincipalFrom(ctx)
+	if !ok {
+		errs.abort(ctx, FailureMissingToken, nil)
+		return nil, false
+	}
+	return p, true
+}
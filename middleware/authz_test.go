@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// asPrincipal 模拟 ValidateToken 写入身份
+func asPrincipal(p *Principal) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if p != nil {
+			SetPrincipal(ctx, p)
+		}
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	policy := NewPolicy(map[int32][]string{
+		1: {"order:read"},
+		2: {"order:read", "order:write"},
+		9: {PermissionAll},
+	})
+	user := &Principal{UID: "1", Role: 1, Scopes: []string{"order:read"}}
+	editor := &Principal{UID: "2", Role: 2, Scopes: []string{"order:read", "order:write"}}
+	admin := &Principal{UID: "9", Role: 9}
+
+	tests := []struct {
+		name       string
+		principal  *Principal
+		mw         gin.HandlerFunc
+		wantStatus int
+	}{
+		{"role allowed", editor, RequireRole(2, 9), http.StatusOK},
+		{"role denied", user, RequireRole(2, 9), http.StatusForbidden},
+		{"role without login", nil, RequireRole(1), http.StatusUnauthorized},
+		{"permission granted", user, RequireAnyPermission(policy, "order:read"), http.StatusOK},
+		{"any of permissions", editor, policy.RequireAnyPermission("user:manage", "order:write"), http.StatusOK},
+		{"permission denied", user, RequireAnyPermission(policy, "order:write"), http.StatusForbidden},
+		{"wildcard permission", admin, RequireAnyPermission(policy, "user:manage"), http.StatusOK},
+		{"unknown role", &Principal{UID: "5", Role: 5}, RequireAnyPermission(policy, "order:read"), http.StatusForbidden},
+		{"permission without login", nil, RequireAnyPermission(policy, "order:read"), http.StatusUnauthorized},
+		{"all scopes granted", editor, RequireScopes("order:read", "order:write"), http.StatusOK},
+		{"missing scope", user, RequireScopes("order:read", "order:write"), http.StatusForbidden},
+		{"scopes without login", nil, RequireScopes("order:read"), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := runAuth(t, "/orders", "/orders", nil, asPrincipal(tt.principal), tt.mw)
+			if res.status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", res.status, tt.wantStatus)
+			}
+			// 默认 errCode 与状态码一致
+			if tt.wantStatus != http.StatusOK && int(res.errCode) != tt.wantStatus {
+				t.Errorf("errCode = %v, want %d", res.errCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAuthorizationCustomErrors(t *testing.T) {
+	var reasons []AuthFailure
+	config := &AuthzConfig{Errors: &AuthErrorConfig{
+		Codes: DetailedAuthErrorCodes,
+		Renderer: func(ctx *gin.Context, e *AuthError) {
+			reasons = append(reasons, e.Reason)
+			ctx.JSON(e.Status, gin.H{"errCode": e.Code, "errMsg": "wrapped: " + e.Message})
+		},
+	}}
+	policy := NewPolicy(map[int32][]string{1: {"order:read"}})
+	user := &Principal{UID: "1", Role: 1, Scopes: []string{"order:read"}}
+
+	tests := []struct {
+		name       string
+		principal  *Principal
+		mw         gin.HandlerFunc
+		wantStatus int
+		wantReason AuthFailure
+	}{
+		{"role denied", user, RequireRoleWithConfig(config, 9), http.StatusForbidden, FailureForbidden},
+		{"role without login", nil, RequireRoleWithConfig(config, 1), http.StatusUnauthorized, FailureMissingToken},
+		{"permission denied", user, RequireAnyPermissionWithConfig(config, policy, "order:write"), http.StatusForbidden, FailureForbidden},
+		{"permission without login", nil, RequireAnyPermissionWithConfig(config, policy, "order:read"), http.StatusUnauthorized, FailureMissingToken},
+		{"missing scope", user, RequireScopesWithConfig(config, "order:write"), http.StatusForbidden, FailureForbidden},
+		{"scopes without login", nil, RequireScopesWithConfig(config, "order:read"), http.StatusUnauthorized, FailureMissingToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reasons = nil
+			res := runAuth(t, "/orders", "/orders", nil, asPrincipal(tt.principal), tt.mw)
+			if res.status != tt.wantStatus || !strings.HasPrefix(res.errMsg, "wrapped: ") {
+				t.Fatalf("status = %d, errMsg = %q, want %d from the custom renderer", res.status, res.errMsg, tt.wantStatus)
+			}
+			if int(res.errCode) != DetailedAuthErrorCodes[tt.wantReason] {
+				t.Errorf("errCode = %v, want %d", res.errCode, DetailedAuthErrorCodes[tt.wantReason])
+			}
+			if len(reasons) != 1 || reasons[0] != tt.wantReason {
+				t.Errorf("renderer reasons = %v, want [%s]", reasons, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestRequireAnyPermissionNilPolicy(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("RequireAnyPermission(nil) should panic at construction")
+		}
+	}()
+	RequireAnyPermission(nil, "order:read")
+}
@@ -6,6 +6,10 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// Cors 允许任意来源的跨域请求
+// 注意：Access-Control-Allow-Origin 为 * 时浏览器不会携带 cookie，需要携带凭证或限制来源时请使用 CORS(config)
+//
+// Deprecated: 请使用 CORS。
 func Cors() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		method := c.Request.Method
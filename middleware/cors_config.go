@@ -0,0 +1,259 @@
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	defaultCORSMethods       = []string{"POST", "GET", "OPTIONS", "DELETE", "PATCH", "PUT"}
+	defaultCORSHeaders       = []string{"Content-Type", "AccessToken", "X-CSRF-Token", "Authorization", "Token", "x-token", "x-requested-with"}
+	defaultCORSExposeHeaders = []string{"Content-Length", "Content-Type", "Authorization", "x-token"}
+)
+
+// CORSConfig CORS 中间件的配置
+type CORSConfig struct {
+	// AllowOrigins 允许的来源：精确匹配如 "https://www.example.com"，
+	// 通配子域名如 "https://*.example.com"（不匹配 example.com 本身），"*" 表示任意来源
+	// 通配符只能作为 scheme:// 之后的子域名，"https://example.*" 等其他写法会 panic
+	AllowOrigins []string
+	// AllowOriginRegexps 正则匹配来源，会自动加上 ^ 和 $ 整体匹配，如 `https://pr-\d+\.preview\.example\.com`
+	// 来源会先转为小写再匹配，正则中请使用小写
+	AllowOriginRegexps []string
+	// AllowOriginFunc 自定义判断，如从配置中心读取白名单，参数为小写的来源；以上任一条件满足即允许
+	AllowOriginFunc func(origin string) bool
+
+	AllowMethods     []string      // 默认 POST, GET, OPTIONS, DELETE, PATCH, PUT
+	AllowHeaders     []string      // 允许的请求头，默认与 Cors() 相同；含 "*" 时允许任意请求头
+	ExposeHeaders    []string      // 前端 JS 可读取的响应头，默认 Content-Length, Content-Type, Authorization, x-token
+	AllowCredentials bool          // 允许携带 cookie；开启后不能与 AllowOrigins "*" 同时使用
+	MaxAge           time.Duration // 预检结果的缓存时间，为 0 时不下发 Access-Control-Max-Age
+	// AllowPrivateNetwork 允许公网页面访问内网服务（Private Network Access），预检请求带
+	// Access-Control-Request-Private-Network: true 时返回 Access-Control-Allow-Private-Network: true
+	AllowPrivateNetwork bool
+
+	// Routes 按路由使用不同的策略，按顺序取第一个命中的，未命中的使用本配置
+	// 预检请求时 gin 还没有匹配到路由模板，请使用 Exact、Prefix、Glob 规则
+	Routes []CORSRoute
+}
+
+// CORSRoute 路由级别的 CORS 策略，Config 中的 Routes 会被忽略
+type CORSRoute struct {
+	Match  *RouteMatcher
+	Config *CORSConfig
+}
+
+// corsPolicy 预先处理过的 CORSConfig
+type corsPolicy struct {
+	allowAll            bool
+	exact               map[string]bool
+	wildcards           [][2]string // 通配符前后两部分，如 {"https://", ".example.com"}
+	regexps             []*regexp.Regexp
+	originFunc          func(string) bool
+	methods             map[string]bool
+	methodsValue        string
+	headers             map[string]bool
+	allowAnyHeader      bool
+	exposeValue         string
+	credentials         bool
+	maxAge              string
+	allowPrivateNetwork bool
+}
+
+func newCORSPolicy(config *CORSConfig) *corsPolicy {
+	p := &corsPolicy{
+		exact:               make(map[string]bool),
+		originFunc:          config.AllowOriginFunc,
+		methods:             make(map[string]bool),
+		headers:             make(map[string]bool),
+		credentials:         config.AllowCredentials,
+		allowPrivateNetwork: config.AllowPrivateNetwork,
+	}
+	for _, o := range config.AllowOrigins {
+		o = strings.ToLower(strings.TrimSuffix(o, "/"))
+		switch {
+		case o == "*":
+			p.allowAll = true
+		case strings.Contains(o, "*"):
+			p.wildcards = append(p.wildcards, parseWildcardOrigin(o))
+		default:
+			p.exact[o] = true
+		}
+	}
+	if p.allowAll && p.credentials {
+		panic("middleware: CORSConfig.AllowOrigins \"*\" cannot be used with AllowCredentials, list the origins or use AllowOriginFunc")
+	}
+	for _, expr := range config.AllowOriginRegexps {
+		p.regexps = append(p.regexps, regexp.MustCompile("^(?:"+expr+")$"))
+	}
+
+	methods := config.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	for _, m := range methods {
+		p.methods[strings.ToUpper(m)] = true
+	}
+	p.methodsValue = strings.ToUpper(strings.Join(methods, ", "))
+
+	headers := config.AllowHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+	for _, h := range headers {
+		if h == "*" {
+			p.allowAnyHeader = true
+		}
+		p.headers[strings.ToLower(h)] = true
+	}
+
+	expose := config.ExposeHeaders
+	if len(expose) == 0 {
+		expose = defaultCORSExposeHeaders
+	}
+	p.exposeValue = strings.Join(expose, ", ")
+	if config.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(config.MaxAge / time.Second))
+	}
+	return p
+}
+
+// allowOrigin 判断来源是否允许，scheme 和域名不区分大小写
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	if p.allowAll {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if p.exact[lower] {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if len(lower) > len(w[0])+len(w[1]) && strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) &&
+			!strings.ContainsAny(lower[len(w[0]):len(lower)-len(w[1])], "/:") {
+			return true
+		}
+	}
+	for _, re := range p.regexps {
+		if re.MatchString(lower) {
+			return true
+		}
+	}
+	return p.originFunc != nil && p.originFunc(lower)
+}
+
+// parseWildcardOrigin 解析 "scheme://*.domain"，返回 {"scheme://", ".domain"}，其他含 * 的写法 panic
+func parseWildcardOrigin(origin string) [2]string {
+	i := strings.Index(origin, "://*.")
+	var scheme, domain string
+	if i > 0 {
+		scheme, domain = origin[:i], origin[i+len("://*."):]
+	}
+	if scheme == "" || strings.ContainsAny(scheme, "*/:.") ||
+		domain == "" || domain[0] == '.' || strings.ContainsAny(domain, "*/") {
+		panic("middleware: invalid CORS origin " + strconv.Quote(origin) + ", wildcard must be a leading subdomain like https://*.example.com")
+	}
+	return [2]string{scheme + "://", "." + domain}
+}
+
+// allowHeaders 预检请求中的 Access-Control-Request-Headers 是否全部允许
+func (p *corsPolicy) allowHeaders(requested string) bool {
+	if p.allowAnyHeader {
+		return true
+	}
+	for _, h := range strings.Split(requested, ",") {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h != "" && !p.headers[h] {
+			return false
+		}
+	}
+	return true
+}
+
+// CORS 按配置处理跨域请求，替代 Cors()
+// 允许的来源原样回写到 Access-Control-Allow-Origin 并带上 Vary: Origin，不允许的来源不写任何 CORS 响应头；
+// 只有带 Access-Control-Request-Method 的 OPTIONS 请求才按预检处理并直接返回，其余 OPTIONS 请求交给后续 handler
+// 需用 r.Use 全局注册，否则未注册 OPTIONS 路由时预检请求到不了中间件
+// 示例 r.Use(middleware.CORS(&middleware.CORSConfig{AllowOrigins: []string{"https://*.example.com"}, AllowCredentials: true, MaxAge: 12 * time.Hour}))
+func CORS(config *CORSConfig) gin.HandlerFunc {
+	base := newCORSPolicy(config)
+	type routePolicy struct {
+		match  *RouteMatcher
+		policy *corsPolicy
+	}
+	routes := make([]routePolicy, 0, len(config.Routes))
+	for _, r := range config.Routes {
+		routes = append(routes, routePolicy{match: r.Match, policy: newCORSPolicy(r.Config)})
+	}
+
+	return func(ctx *gin.Context) {
+		origin := ctx.GetHeader("Origin")
+		if origin == "" {
+			// 非跨域请求
+			return
+		}
+		policy := base
+		for _, r := range routes {
+			if _, ok := r.match.Match(ctx); ok {
+				policy = r.policy
+				break
+			}
+		}
+
+		header := ctx.Writer.Header()
+		header.Add("Vary", "Origin")
+		preflight := ctx.Request.Method == http.MethodOptions && ctx.GetHeader("Access-Control-Request-Method") != ""
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !policy.allowOrigin(origin) {
+			if preflight {
+				ctx.AbortWithStatus(http.StatusForbidden)
+			}
+			// 非预检请求照常处理，浏览器因缺少 CORS 响应头而不让页面读取结果
+			return
+		}
+
+		requestMethod := strings.ToUpper(ctx.GetHeader("Access-Control-Request-Method"))
+		requestHeaders := ctx.GetHeader("Access-Control-Request-Headers")
+		if preflight && (!policy.methods[requestMethod] || !policy.allowHeaders(requestHeaders)) {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		if policy.allowAll {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if policy.credentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if policy.exposeValue != "" {
+				header.Set("Access-Control-Expose-Headers", policy.exposeValue)
+			}
+			return
+		}
+
+		header.Set("Access-Control-Allow-Methods", policy.methodsValue)
+		if requestHeaders != "" {
+			// 回写请求的头，AllowHeaders 含 "*" 且携带凭证时浏览器不认 "*"，回写也能正常工作
+			header.Set("Access-Control-Allow-Headers", requestHeaders)
+		}
+		if policy.maxAge != "" {
+			header.Set("Access-Control-Max-Age", policy.maxAge)
+		}
+		if policy.allowPrivateNetwork && strings.EqualFold(ctx.GetHeader("Access-Control-Request-Private-Network"), "true") {
+			header.Set("Access-Control-Allow-Private-Network", "true")
+		}
+		ctx.AbortWithStatus(http.StatusNoContent)
+	}
+}
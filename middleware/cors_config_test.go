@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORSAllowOrigin(t *testing.T) {
+	config := &CORSConfig{
+		AllowOrigins:       []string{"https://www.example.com/", "https://*.example.org"},
+		AllowOriginRegexps: []string{`https://pr-\d+\.preview\.example\.net`},
+		AllowOriginFunc:    func(origin string) bool { return origin == "https://partner.example.io" },
+	}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://www.example.com", true},
+		{"HTTPS://WWW.EXAMPLE.COM", true},
+		{"http://www.example.com", false},
+		{"https://www.example.com.evil.com", false},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://A.Example.ORG", true},
+		{"https://example.org", false},
+		{"https://.example.org", false},
+		{"https://evil.com/.example.org", false},
+		{"https://evil.com:443.example.org", false},
+		{"https://evilexample.org", false},
+		{"https://pr-12.preview.example.net", true},
+		{"https://PR-12.Preview.Example.NET", true},
+		{"https://pr-x.preview.example.net", false},
+		{"https://pr-12.preview.example.net.evil.com", false},
+		{"https://partner.example.io", true},
+		{"https://Partner.Example.IO", true},
+		{"https://other.example.io", false},
+	}
+	p := newCORSPolicy(config)
+	for _, tt := range tests {
+		if got := p.allowOrigin(tt.origin); got != tt.want {
+			t.Errorf("allowOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestCORSInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *CORSConfig
+	}{
+		{"trailing wildcard", &CORSConfig{AllowOrigins: []string{"https://example.*"}}},
+		{"wildcard in the middle", &CORSConfig{AllowOrigins: []string{"https://api.*.example.com"}}},
+		{"wildcard without dot", &CORSConfig{AllowOrigins: []string{"https://*example.com"}}},
+		{"wildcard scheme", &CORSConfig{AllowOrigins: []string{"*://www.example.com"}}},
+		{"two wildcards", &CORSConfig{AllowOrigins: []string{"https://*.*.example.com"}}},
+		{"wildcard without domain", &CORSConfig{AllowOrigins: []string{"https://*."}}},
+		{"any origin with credentials", &CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true}},
+		{"invalid regexp", &CORSConfig{AllowOriginRegexps: []string{"("}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("CORS should panic")
+				}
+			}()
+			CORS(tt.config)
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	mw := CORS(&CORSConfig{
+		AllowOrigins:        []string{"https://*.example.com"},
+		AllowHeaders:        []string{"Content-Type", "Authorization"},
+		AllowCredentials:    true,
+		MaxAge:              12 * time.Hour,
+		AllowPrivateNetwork: true,
+		Routes: []CORSRoute{{
+			Match:  NewRouteMatcher(Prefix("/public")),
+			Config: &CORSConfig{AllowOrigins: []string{"*"}},
+		}},
+	})
+	preflight := func(method, headers string) func(*http.Request) {
+		return func(r *http.Request) {
+			r.Method = http.MethodOptions
+			r.Header.Set("Access-Control-Request-Method", method)
+			if headers != "" {
+				r.Header.Set("Access-Control-Request-Headers", headers)
+			}
+		}
+	}
+	tests := []struct {
+		name        string
+		path        string
+		origin      string
+		setup       func(*http.Request)
+		wantStatus  int
+		wantAllow   string
+		wantCreds   string
+		wantMaxAge  string
+		wantHandled bool // 是否到达 handler
+	}{
+		{"same origin", "/api", "", nil, http.StatusOK, "", "", "", true},
+		{"allowed simple request", "/api", "https://app.example.com", nil, http.StatusOK, "https://app.example.com", "true", "", true},
+		{"disallowed simple request", "/api", "https://evil.com", nil, http.StatusOK, "", "", "", true},
+		{"allowed preflight", "/api", "https://app.example.com", preflight("PUT", "content-type, authorization"), http.StatusNoContent, "https://app.example.com", "true", "43200", false},
+		{"disallowed origin preflight", "/api", "https://evil.com", preflight("PUT", ""), http.StatusForbidden, "", "", "", false},
+		{"disallowed method preflight", "/api", "https://app.example.com", preflight("TRACE", ""), http.StatusForbidden, "", "", "", false},
+		{"disallowed header preflight", "/api", "https://app.example.com", preflight("PUT", "x-custom"), http.StatusForbidden, "", "", "", false},
+		{"plain options", "/api", "https://app.example.com", func(r *http.Request) { r.Method = http.MethodOptions }, http.StatusOK, "https://app.example.com", "true", "", true},
+		{"route policy", "/public/news", "https://evil.com", nil, http.StatusOK, "*", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handled := false
+			r := gin.New()
+			r.Use(mw)
+			r.Any("/*path", func(ctx *gin.Context) {
+				handled = true
+				ctx.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.setup != nil {
+				tt.setup(req)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			h := w.Header()
+			if w.Code != tt.wantStatus || handled != tt.wantHandled {
+				t.Fatalf("status = %d, handled = %v, want %d, %v", w.Code, handled, tt.wantStatus, tt.wantHandled)
+			}
+			if h.Get("Access-Control-Allow-Origin") != tt.wantAllow || h.Get("Access-Control-Allow-Credentials") != tt.wantCreds ||
+				h.Get("Access-Control-Max-Age") != tt.wantMaxAge {
+				t.Errorf("headers = %v", h)
+			}
+			if tt.origin != "" && !strings.Contains(strings.Join(h.Values("Vary"), ","), "Origin") {
+				t.Errorf("Vary = %v, want Origin", h.Values("Vary"))
+			}
+		})
+	}
+}
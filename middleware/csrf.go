@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/ccnj/go-utils/log"
+	"github.com/ccnj/go-utils/rand/cryptorand"
+	"github.com/gin-gonic/gin"
+)
+
+// CSRFTokenKey gin.Context 中保存当前 CSRF token 的 key，服务端渲染页面时可写入表单
+const CSRFTokenKey = "csrf_token"
+
+// CSRFConfig CSRF 中间件的配置，除 Secret 外零值字段使用默认值
+type CSRFConfig struct {
+	// Secret 必填，对 token 做 HMAC 签名，多实例部署时须一致，至少 32 字节
+	// 签名绑定 SessionID，子域名或中间人写入（cookie tossing）的 token 在受害者的会话中无法通过校验
+	Secret []byte
+	// SessionID 返回当前会话的标识，token 与之绑定；默认取 ValidateToken 写入的 uid，
+	// 因此 CSRF 需放在 ValidateToken 之后，未登录时绑定空会话
+	// 会话变化（如登录、切换账号）后旧 token 失效，中间件会下发新 token，前端每次从 cookie 读取即可
+	SessionID    func(ctx *gin.Context) string
+	CookieName   string        // 保存 token 的 cookie，默认 csrf_token；全站 HTTPS 时可用 "__Host-csrf_token" 进一步限制写入
+	HeaderName   string        // 前端回传 token 的请求头，默认 X-CSRF-Token（Cors 中已允许）
+	FormField    string        // 表单提交时回传 token 的字段，默认 csrf_token
+	TokenLength  int           // token 随机部分的长度，默认 32
+	CookiePath   string        // 默认 /
+	CookieDomain string        // 默认为空，只对当前域名生效
+	CookieMaxAge int           // cookie 有效期（秒），默认 0 即会话 cookie
+	Secure       bool          // 只在 HTTPS 下发送 cookie，生产环境应开启
+	SameSite     http.SameSite // 默认 http.SameSiteLaxMode；跨站嵌入场景可设为 http.SameSiteNoneMode，需同时开启 Secure
+	Exempt       *RouteMatcher // 不做校验的路由，如第三方回调、使用 Authorization 头认证的 API
+	// Errors 失败响应的配置，为空时使用 DefaultAuthErrors；校验失败的原因为 FailureInvalidCSRFToken
+	Errors *AuthErrorConfig
+}
+
+// CSRF 双重提交 cookie 方式的 CSRF 防护，用于从 cookie 读取 token 的场景
+// 首次访问时下发 csrf_token cookie（前端 JS 可读），之后 POST、PUT、PATCH、DELETE 等请求
+// 须把 cookie 中的值放入 X-CSRF-Token 请求头或 csrf_token 表单字段，与 cookie 不一致则返回 403
+// 第三方站点无法读取本站 cookie，也就无法构造正确的请求头；token 带有绑定会话的签名，
+// 能写入 cookie 的子域名也无法伪造当前用户可用的 token
+// 示例 r.Use(middleware.ValidateToken(key, nil), middleware.CSRF(&middleware.CSRFConfig{Secret: csrfSecret, Secure: true}))
+func CSRF(config *CSRFConfig) gin.HandlerFunc {
+	if len(config.Secret) < 32 {
+		panic("middleware: CSRFConfig.Secret must be at least 32 bytes")
+	}
+	secret := config.Secret
+	sessionID := config.SessionID
+	if sessionID == nil {
+		sessionID = func(ctx *gin.Context) string { return ctx.GetString("uid") }
+	}
+	cookieName := defaultString(config.CookieName, "csrf_token")
+	headerName := defaultString(config.HeaderName, "X-CSRF-Token")
+	formField := defaultString(config.FormField, "csrf_token")
+	cookiePath := defaultString(config.CookiePath, "/")
+	tokenLength := config.TokenLength
+	if tokenLength <= 0 {
+		tokenLength = 32
+	}
+	sameSite := config.SameSite
+	if sameSite == 0 {
+		sameSite = http.SameSiteLaxMode
+	}
+	errs := config.Errors
+
+	return func(ctx *gin.Context) {
+		session := sessionID(ctx)
+		cookieToken, _ := ctx.Cookie(cookieName)
+		csrfToken := cookieToken
+		if !verifyCSRFToken(secret, session, cookieToken, tokenLength) {
+			nonce, err := cryptorand.GenCryptoRandStr(tokenLength)
+			if err != nil {
+				log.Error(ctx, "生成 CSRF token 失败", "err", err)
+				errs.abort(ctx, FailureUnavailable, err)
+				return
+			}
+			csrfToken = signCSRFToken(secret, session, nonce)
+			// 前端需要读取 cookie 放入请求头，所以不能是 HttpOnly
+			http.SetCookie(ctx.Writer, &http.Cookie{
+				Name:     cookieName,
+				Value:    csrfToken,
+				Path:     cookiePath,
+				Domain:   config.CookieDomain,
+				MaxAge:   config.CookieMaxAge,
+				Secure:   config.Secure,
+				HttpOnly: false,
+				SameSite: sameSite,
+			})
+		}
+		ctx.Set(CSRFTokenKey, csrfToken)
+
+		if isSafeMethod(ctx.Request.Method) {
+			return
+		}
+		if _, ok := config.Exempt.Match(ctx); ok {
+			return
+		}
+
+		submitted := ctx.GetHeader(headerName)
+		if submitted == "" {
+			submitted = ctx.PostForm(formField)
+		}
+		// 本次请求才下发的 token 一定不是前端提交的，cookie 缺失、签名不符时同样拒绝
+		if cookieToken == "" || cookieToken != csrfToken ||
+			subtle.ConstantTimeCompare([]byte(submitted), []byte(cookieToken)) != 1 {
+			log.Info(ctx, "CSRF 校验失败", "path", ctx.Request.URL.Path, "has_cookie", cookieToken != "", "has_token", submitted != "")
+			errs.abort(ctx, FailureInvalidCSRFToken, nil)
+			return
+		}
+	}
+}
+
+// signCSRFToken 生成 "<随机串>.<签名>"，签名为 base64url(HMAC-SHA256(session + "\n" + 随机串))
+func signCSRFToken(secret []byte, session, nonce string) string {
+	return nonce + "." + csrfMAC(secret, session, nonce)
+}
+
+// verifyCSRFToken 校验 token 的格式和绑定当前会话的签名
+func verifyCSRFToken(secret []byte, session, csrfToken string, nonceLength int) bool {
+	nonce, sig, ok := strings.Cut(csrfToken, ".")
+	if !ok || len(nonce) != nonceLength {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(csrfMAC(secret, session, nonce)))
+}
+
+func csrfMAC(secret []byte, session, nonce string) string {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(session + "\n" + nonce))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// CSRFToken 当前请求的 CSRF token，需在 CSRF 中间件之后调用
+func CSRFToken(ctx *gin.Context) string {
+	return ctx.GetString(CSRFTokenKey)
+}
+
+// isSafeMethod RFC 9110 定义的安全方法，不应产生副作用，无需校验
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
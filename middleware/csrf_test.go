@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testCSRFSecret = []byte("csrf-secret-0123456789abcdef0123")
+
+func TestCSRF(t *testing.T) {
+	const nonce = "0123456789ABCDEFGHIJKLMNOPQRSTUV"
+	userToken := signCSRFToken(testCSRFSecret, "10086", nonce)
+	anonToken := signCSRFToken(testCSRFSecret, "", nonce)
+	// 攻击者用自己账号拿到的 token，写入受害者的 cookie
+	attackerToken := signCSRFToken(testCSRFSecret, "666", nonce)
+	forgedToken := nonce + ".forged"
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		uid        string
+		cookie     string
+		header     string
+		form       string
+		wantStatus int
+		wantCookie bool // 是否下发新 token
+	}{
+		{"get issues token", http.MethodGet, "/orders", "10086", "", "", "", http.StatusOK, true},
+		{"get keeps valid token", http.MethodGet, "/orders", "10086", userToken, "", "", http.StatusOK, false},
+		{"post with header", http.MethodPost, "/orders", "10086", userToken, userToken, "", http.StatusOK, false},
+		{"post with form field", http.MethodPost, "/orders", "10086", userToken, "", userToken, http.StatusOK, false},
+		{"anonymous post", http.MethodPost, "/login", "", anonToken, anonToken, "", http.StatusOK, false},
+		{"post without header", http.MethodPost, "/orders", "10086", userToken, "", "", http.StatusForbidden, false},
+		{"post with wrong header", http.MethodPost, "/orders", "10086", userToken, anonToken, "", http.StatusForbidden, false},
+		{"post without cookie", http.MethodPost, "/orders", "10086", "", userToken, "", http.StatusForbidden, true},
+		{"tossed cookie of another user", http.MethodPost, "/orders", "10086", attackerToken, attackerToken, "", http.StatusForbidden, true},
+		{"anonymous token after login", http.MethodPost, "/orders", "10086", anonToken, anonToken, "", http.StatusForbidden, true},
+		{"unsigned token", http.MethodPost, "/orders", "10086", forgedToken, forgedToken, "", http.StatusForbidden, true},
+		{"exempt route", http.MethodPost, "/callback/pay", "", "", "", "", http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(ctx *gin.Context) { ctx.Set("uid", tt.uid) }, CSRF(&CSRFConfig{
+				Secret: testCSRFSecret,
+				Exempt: NewRouteMatcher(Prefix("/callback")),
+			}))
+			r.Any("/*path", func(ctx *gin.Context) { ctx.String(http.StatusOK, CSRFToken(ctx)) })
+
+			var body *strings.Reader
+			if tt.form != "" {
+				body = strings.NewReader(url.Values{"csrf_token": {tt.form}}.Encode())
+			} else {
+				body = strings.NewReader("")
+			}
+			req := httptest.NewRequest(tt.method, tt.path, body)
+			if tt.form != "" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "csrf_token", Value: tt.cookie})
+			}
+			if tt.header != "" {
+				req.Header.Set("X-CSRF-Token", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == http.StatusForbidden && !strings.Contains(w.Body.String(), "页面已过期，请刷新后重试") {
+				t.Errorf("body = %s", w.Body)
+			}
+			var issued *http.Cookie
+			for _, c := range w.Result().Cookies() {
+				if c.Name == "csrf_token" {
+					issued = c
+				}
+			}
+			if (issued != nil) != tt.wantCookie {
+				t.Fatalf("issued cookie = %v, want %v", issued, tt.wantCookie)
+			}
+			if issued != nil {
+				if issued.HttpOnly || issued.SameSite != http.SameSiteLaxMode || issued.Path != "/" {
+					t.Errorf("cookie attributes = %+v", issued)
+				}
+				if !verifyCSRFToken(testCSRFSecret, tt.uid, issued.Value, len(nonce)) {
+					t.Errorf("issued token %q is not bound to session %q", issued.Value, tt.uid)
+				}
+			}
+		})
+	}
+}
+
+func TestCSRFErrors(t *testing.T) {
+	config := &CSRFConfig{Secret: testCSRFSecret, Errors: &AuthErrorConfig{Codes: DetailedAuthErrorCodes}}
+	res := runPost(t, CSRF(config), "en")
+	if res.status != http.StatusForbidden || res.errCode != 40301 || res.errMsg != "The page has expired, please refresh and try again." {
+		t.Errorf("status = %d, errCode = %v, errMsg = %q", res.status, res.errCode, res.errMsg)
+	}
+	if res.header.Get("WWW-Authenticate") != "" {
+		t.Error("CSRF failure should not carry WWW-Authenticate")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("CSRF without a 32-byte secret should panic")
+		}
+	}()
+	CSRF(&CSRFConfig{Secret: []byte("short")})
+}
+
+// runPost 发送不带 CSRF token 的 POST 请求
+func runPost(t *testing.T, mw gin.HandlerFunc, lang string) authResult {
+	t.Helper()
+	r := gin.New()
+	r.POST("/orders", mw, func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	req.Header.Set("Accept-Language", lang)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	res := authResult{status: w.Code, header: w.Header()}
+	var body struct {
+		ErrCode float64 `json:"errCode"`
+		ErrMsg  string  `json:"errMsg"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	res.errCode, res.errMsg = body.ErrCode, body.ErrMsg
+	return res
+}
@@ -1,14 +1,100 @@
 package middleware
 
 import (
+	"context"
+
+	"github.com/ccnj/go-utils/idgen"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
-func GenRequestId2Ctx() gin.HandlerFunc {
+// RequestIdKey gin.Context 中保存 request id 的 key，log 包和 GenCctx2Ctx 据此读取
+const RequestIdKey = "request_id"
+
+type requestIdCtxKey struct{}
+
+// RequestIdConfig GenRequestId2Ctx 的配置
+type RequestIdConfig struct {
+	Header        string // 读取上游（nginx、ingress）传入的 request id 并回写到响应的请求头，默认 X-Request-Id
+	IgnoreInbound bool   // 不采用请求中的 request id，总是重新生成，用于直接面向公网、没有网关的服务
+	DisableEcho   bool   // 不在响应头中返回 request id
+	MaxLength     int    // 请求中 request id 的最大长度，默认 128，超长或含非法字符时重新生成
+	// Generator 生成 request id 的方式，默认 idgen.UUIDv4()；
+	// 用 idgen.UUIDv7()、idgen.ULID() 可按时间排序，便于日志检索
+	Generator idgen.Generator
+}
+
+// GenRequestId2Ctx 生成或沿用 request id，存入 gin.Context 和 ctx.Request.Context()，并在响应头中返回，方便用户反馈问题时提供
+// 请求中的 request id 只接受字母、数字和 - _ . :，防止日志注入
+// 示例 r.Use(middleware.GenRequestId2Ctx()) 或 r.Use(middleware.GenRequestId2Ctx(&middleware.RequestIdConfig{Header: "X-Trace-Id", Generator: idgen.ULID()}))
+func GenRequestId2Ctx(config ...*RequestIdConfig) gin.HandlerFunc {
+	cfg := &RequestIdConfig{}
+	if len(config) > 0 && config[0] != nil {
+		cfg = config[0]
+	}
+	header := defaultString(cfg.Header, "X-Request-Id")
+	maxLength := cfg.MaxLength
+	if maxLength <= 0 {
+		maxLength = 128
+	}
+	generator := cfg.Generator
+	if generator == nil {
+		generator = idgen.UUIDv4()
+	}
+
 	return func(ctx *gin.Context) {
+		var requestId string
+		if !cfg.IgnoreInbound {
+			if inbound := ctx.GetHeader(header); validRequestId(inbound, maxLength) {
+				requestId = inbound
+			}
+		}
+		if requestId == "" {
+			requestId = generator.Generate()
+		}
+
 		// 存requestId
-		requestId := uuid.New().String()
-		ctx.Set("request_id", requestId)
+		ctx.Set(RequestIdKey, requestId)
+		ctx.Request = ctx.Request.WithContext(ContextWithRequestId(ctx.Request.Context(), requestId))
+		if !cfg.DisableEcho {
+			ctx.Header(header, requestId)
+		}
+	}
+}
+
+// validRequestId 长度在 1 到 maxLength 之间，且只含字母、数字和 - _ . :
+func validRequestId(id string, maxLength int) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ContextWithRequestId 把 request id 存入 context.Context
+func ContextWithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdCtxKey{}, requestId)
+}
+
+// RequestIdFrom 读取 request id，gin.Context 和 context.Context 均可，没有时返回空字符串
+// 示例 requestId := middleware.RequestIdFrom(ctx.Request.Context())
+func RequestIdFrom(ctx context.Context) string {
+	if gctx, ok := ctx.(*gin.Context); ok {
+		if id := gctx.GetString(RequestIdKey); id != "" {
+			return id
+		}
+		if gctx.Request == nil {
+			return ""
+		}
+		ctx = gctx.Request.Context()
 	}
+	id, _ := ctx.Value(requestIdCtxKey{}).(string)
+	return id
 }
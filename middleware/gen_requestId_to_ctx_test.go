@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ccnj/go-utils/idgen"
+	"github.com/gin-gonic/gin"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+func TestGenRequestId2Ctx(t *testing.T) {
+	fixed := idgen.GeneratorFunc(func() string { return "generated" })
+	tests := []struct {
+		name       string
+		config     *RequestIdConfig
+		header     string // 请求头名
+		inbound    string
+		want       string // 为空表示应为新生成的 uuid
+		wantHeader string // 响应头名，为空表示不回写
+	}{
+		{"generate uuid", nil, "X-Request-Id", "", "", "X-Request-Id"},
+		{"honor inbound", nil, "X-Request-Id", "req-1:a_b.c", "req-1:a_b.c", "X-Request-Id"},
+		{"reject injected newline", nil, "X-Request-Id", "req-1\nlevel=error", "", "X-Request-Id"},
+		{"reject spaces", nil, "X-Request-Id", "req 1", "", "X-Request-Id"},
+		{"reject too long", nil, "X-Request-Id", strings.Repeat("a", 129), "", "X-Request-Id"},
+		{"custom max length", &RequestIdConfig{MaxLength: 200}, "X-Request-Id", strings.Repeat("a", 129), strings.Repeat("a", 129), "X-Request-Id"},
+		{"ignore inbound", &RequestIdConfig{IgnoreInbound: true, Generator: fixed}, "X-Request-Id", "req-1", "generated", "X-Request-Id"},
+		{"disable echo", &RequestIdConfig{DisableEcho: true}, "X-Request-Id", "req-1", "req-1", ""},
+		{"custom header", &RequestIdConfig{Header: "X-Trace-Id"}, "X-Trace-Id", "trace-1", "trace-1", "X-Trace-Id"},
+		{"custom header ignores default", &RequestIdConfig{Header: "X-Trace-Id", Generator: fixed}, "X-Request-Id", "req-1", "generated", "X-Trace-Id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromGin, fromRequest string
+			r := gin.New()
+			r.GET("/", GenRequestId2Ctx(tt.config), func(ctx *gin.Context) {
+				fromGin = RequestIdFrom(ctx)
+				fromRequest = RequestIdFrom(ctx.Request.Context())
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.inbound != "" {
+				req.Header[http.CanonicalHeaderKey(tt.header)] = []string{tt.inbound}
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if tt.want == "" {
+				if !uuidPattern.MatchString(fromGin) {
+					t.Errorf("request id = %q, want a new uuid", fromGin)
+				}
+			} else if fromGin != tt.want {
+				t.Errorf("request id = %q, want %q", fromGin, tt.want)
+			}
+			if fromRequest != fromGin {
+				t.Errorf("request context id = %q, gin id = %q", fromRequest, fromGin)
+			}
+			for _, h := range []string{"X-Request-Id", "X-Trace-Id"} {
+				want := ""
+				if h == tt.wantHeader {
+					want = fromGin
+				}
+				if got := w.Header().Get(h); got != want {
+					t.Errorf("response %s = %q, want %q", h, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ccnj/go-utils/token"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GrpcAuthorizationKey gRPC 元数据中携带 token 的 key，值为 "Bearer <token>"
+const GrpcAuthorizationKey = "authorization"
+
+// GrpcAuthConfig gRPC 服务端认证拦截器的配置
+type GrpcAuthConfig struct {
+	token.VerifierConfig // 与 ValidateTokenConfig 相同的 key、算法、iss、aud、吊销等配置
+	// SkipMethods 不需要验证的方法，完整方法名如 "/user.UserService/Login"，以 / 结尾表示整个服务，如 "/grpc.health.v1.Health/"
+	SkipMethods []string
+	// Optional 可选登录的方法，写法同 SkipMethods：带了 token 就验证，没带按匿名处理，token 无效仍然拒绝
+	Optional []string
+	// Errors 错误信息的提示语，为空时使用 DefaultAuthErrors；只使用其中的 Messages 和 DefaultLanguage
+	Errors *AuthErrorConfig
+}
+
+// grpcAuthenticator 一元和流式拦截器共用的验证逻辑
+type grpcAuthenticator struct {
+	verifier *token.Verifier
+	skip     []string
+	optional []string
+	errs     *AuthErrorConfig
+}
+
+func newGrpcAuthenticator(config *GrpcAuthConfig) *grpcAuthenticator {
+	verifier, err := token.NewVerifier(&config.VerifierConfig)
+	if err != nil {
+		panic(err)
+	}
+	errs := config.Errors
+	if errs == nil {
+		errs = DefaultAuthErrors
+	}
+	return &grpcAuthenticator{
+		verifier: verifier,
+		skip:     config.SkipMethods,
+		optional: config.Optional,
+		errs:     errs,
+	}
+}
+
+// GrpcUnaryServerAuth 一元调用的认证拦截器，验证通过后可在 handler 中用 PrincipalFrom(ctx) 读取身份
+// 示例 grpc.NewServer(grpc.ChainUnaryInterceptor(middleware.GrpcUnaryServerAuth(&middleware.GrpcAuthConfig{...})))
+func GrpcUnaryServerAuth(config *GrpcAuthConfig) grpc.UnaryServerInterceptor {
+	a := newGrpcAuthenticator(config)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// GrpcStreamServerAuth 流式调用的认证拦截器
+func GrpcStreamServerAuth(config *GrpcAuthConfig) grpc.StreamServerInterceptor {
+	a := newGrpcAuthenticator(config)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authServerStream 替换 Context()，让流式 handler 也能读取身份
+type authServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate 验证 token 并把身份存入 ctx
+func (a *grpcAuthenticator) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	if matchGrpcMethod(a.skip, fullMethod) {
+		return ctx, nil
+	}
+	var authValue string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(GrpcAuthorizationKey); len(values) > 0 {
+			authValue = values[0]
+		}
+	}
+	tokenStr, err := parseAuthScheme(authValue, "Bearer")
+	if err != nil {
+		return nil, a.statusError(FailureMalformedHeader)
+	}
+	if tokenStr == "" {
+		if matchGrpcMethod(a.optional, fullMethod) {
+			return ctx, nil
+		}
+		return nil, a.statusError(FailureMissingToken)
+	}
+
+	claims, err := a.verifier.VerifyContext(ctx, tokenStr)
+	if err != nil {
+		logVerifyFailure(ctx, tokenStr, err, "method", fullMethod)
+		return nil, a.statusError(failureFromVerifyErr(err))
+	}
+	return ContextWithPrincipal(ctx, &Principal{
+		UID:    claims.UID,
+		Role:   claims.Role,
+		Scopes: strings.Fields(claims.Scope),
+		Claims: claims,
+		Token:  tokenStr,
+	}), nil
+}
+
+// statusError 存储不可用时返回 Unavailable，客户端可以重试；其余返回 Unauthenticated
+func (a *grpcAuthenticator) statusError(reason AuthFailure) error {
+	code := codes.Unauthenticated
+	if reason == FailureUnavailable {
+		code = codes.Unavailable
+	}
+	return status.Error(code, a.errs.message("", reason))
+}
+
+// matchGrpcMethod 完整方法名相等，或以 / 结尾的服务名前缀匹配
+func matchGrpcMethod(patterns []string, fullMethod string) bool {
+	for _, p := range patterns {
+		if p == fullMethod || (strings.HasSuffix(p, "/") && strings.HasPrefix(fullMethod, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// GrpcUnaryClientForwardToken 客户端拦截器，把 ctx 中身份的原始 token 以 authorization 元数据转发给下游
+// ctx 需带有身份：gin 中使用 GenCctx2Ctx 生成的 cctx，gRPC 服务中直接使用 handler 的 ctx
+// 已设置 authorization 的调用不会被覆盖
+func GrpcUnaryClientForwardToken() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withForwardedToken(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// GrpcStreamClientForwardToken 流式调用的 token 转发拦截器
+func GrpcStreamClientForwardToken() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withForwardedToken(ctx), desc, cc, method, opts...)
+	}
+}
+
+func withForwardedToken(ctx context.Context) context.Context {
+	p, ok := PrincipalFrom(ctx)
+	if !ok || p.Token == "" {
+		return ctx
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(GrpcAuthorizationKey)) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, GrpcAuthorizationKey, "Bearer "+p.Token)
+}
+
+// ForwardTokenCredentials 以 PerRPCCredentials 的方式转发 token，与 GrpcUnaryClientForwardToken 二选一
+// 示例 grpc.NewClient(addr, grpc.WithPerRPCCredentials(middleware.ForwardTokenCredentials{}))
+type ForwardTokenCredentials struct {
+	// RequireTLS 为 true 时只允许在 TLS 连接上发送 token
+	RequireTLS bool
+}
+
+func (c ForwardTokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	p, ok := PrincipalFrom(ctx)
+	if !ok || p.Token == "" {
+		return nil, nil
+	}
+	return map[string]string{GrpcAuthorizationKey: "Bearer " + p.Token}, nil
+}
+
+func (c ForwardTokenCredentials) RequireTransportSecurity() bool {
+	return c.RequireTLS
+}
+
+var _ credentials.PerRPCCredentials = ForwardTokenCredentials{}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ccnj/go-utils/token"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// incoming 模拟客户端带 authorization 元数据的入站 ctx
+func incoming(authValue string) context.Context {
+	if authValue == "" {
+		return context.Background()
+	}
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(GrpcAuthorizationKey, authValue))
+}
+
+// fakeServerStream 只提供 Context 的 ServerStream
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context { return s.ctx }
+
+func TestGrpcServerAuth(t *testing.T) {
+	valid := "Bearer " + signToken(t, "10086")
+	expired := "Bearer " + signToken(t, "10086", func(c *token.Claims) {
+		c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	})
+	config := &GrpcAuthConfig{
+		VerifierConfig: hmacVerifierConfig(),
+		SkipMethods:    []string{"/user.UserService/Login", "/grpc.health.v1.Health/"},
+		Optional:       []string{"/feed.FeedService/List"},
+	}
+	revokedCfg := hmacVerifierConfig()
+	revokedCfg.Revocation = failingRevocationStore{token.NewMemoryRevocationStore(10)}
+
+	tests := []struct {
+		name     string
+		config   *GrpcAuthConfig
+		method   string
+		auth     string
+		wantCode codes.Code
+		wantUID  string
+	}{
+		{"valid token", config, "/order.OrderService/Get", valid, codes.OK, "10086"},
+		{"missing token", config, "/order.OrderService/Get", "", codes.Unauthenticated, ""},
+		{"malformed header", config, "/order.OrderService/Get", "Basic abc", codes.Unauthenticated, ""},
+		{"expired token", config, "/order.OrderService/Get", expired, codes.Unauthenticated, ""},
+		{"skipped method", config, "/user.UserService/Login", "", codes.OK, ""},
+		{"skipped service", config, "/grpc.health.v1.Health/Check", "", codes.OK, ""},
+		{"service prefix needs slash", config, "/user.UserService/LoginBySms", "", codes.Unauthenticated, ""},
+		{"optional without token", config, "/feed.FeedService/List", "", codes.OK, ""},
+		{"optional with token", config, "/feed.FeedService/List", valid, codes.OK, "10086"},
+		{"optional with invalid token", config, "/feed.FeedService/List", expired, codes.Unauthenticated, ""},
+		{"revocation store down", &GrpcAuthConfig{VerifierConfig: revokedCfg}, "/order.OrderService/Get", valid, codes.Unavailable, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var uid string
+			unary := GrpcUnaryServerAuth(tt.config)
+			_, err := unary(incoming(tt.auth), nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				if p, ok := PrincipalFrom(ctx); ok {
+					uid = p.UID
+				}
+				return nil, nil
+			})
+			if status.Code(err) != tt.wantCode || uid != tt.wantUID {
+				t.Errorf("unary: code = %s, uid = %q, want %s, %q", status.Code(err), uid, tt.wantCode, tt.wantUID)
+			}
+
+			uid = ""
+			stream := GrpcStreamServerAuth(tt.config)
+			err = stream(nil, &fakeServerStream{ctx: incoming(tt.auth)}, &grpc.StreamServerInfo{FullMethod: tt.method}, func(srv interface{}, ss grpc.ServerStream) error {
+				if p, ok := PrincipalFrom(ss.Context()); ok {
+					uid = p.UID
+				}
+				return nil
+			})
+			if status.Code(err) != tt.wantCode || uid != tt.wantUID {
+				t.Errorf("stream: code = %s, uid = %q, want %s, %q", status.Code(err), uid, tt.wantCode, tt.wantUID)
+			}
+		})
+	}
+}
+
+func TestGrpcForwardToken(t *testing.T) {
+	withToken := ContextWithPrincipal(context.Background(), &Principal{UID: "10086", Token: "tok"})
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want []string
+	}{
+		{"forward principal token", withToken, []string{"Bearer tok"}},
+		{"anonymous", context.Background(), nil},
+		{"principal without token", ContextWithPrincipal(context.Background(), &Principal{UID: "10086"}), nil},
+		{"explicit authorization kept", metadata.AppendToOutgoingContext(withToken, GrpcAuthorizationKey, "Bearer other"), []string{"Bearer other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var unaryGot, streamGot []string
+			unary := GrpcUnaryClientForwardToken()
+			_ = unary(tt.ctx, "/order.OrderService/Get", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				md, _ := metadata.FromOutgoingContext(ctx)
+				unaryGot = md.Get(GrpcAuthorizationKey)
+				return nil
+			})
+			stream := GrpcStreamClientForwardToken()
+			_, _ = stream(tt.ctx, &grpc.StreamDesc{}, nil, "/order.OrderService/Watch", func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				md, _ := metadata.FromOutgoingContext(ctx)
+				streamGot = md.Get(GrpcAuthorizationKey)
+				return nil, nil
+			})
+			if !slices.Equal(unaryGot, tt.want) || !slices.Equal(streamGot, tt.want) {
+				t.Errorf("unary = %v, stream = %v, want %v", unaryGot, streamGot, tt.want)
+			}
+		})
+	}
+}
+
+func TestForwardTokenCredentials(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"principal token", ContextWithPrincipal(context.Background(), &Principal{UID: "10086", Token: "tok"}), "Bearer tok"},
+		{"anonymous", context.Background(), ""},
+	}
+	for _, tt := range tests {
+		md, err := ForwardTokenCredentials{}.GetRequestMetadata(tt.ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if md[GrpcAuthorizationKey] != tt.want {
+			t.Errorf("%s: metadata = %v, want %q", tt.name, md, tt.want)
+		}
+	}
+	if !(ForwardTokenCredentials{RequireTLS: true}).RequireTransportSecurity() {
+		t.Error("RequireTransportSecurity should follow RequireTLS")
+	}
+}
@@ -0,0 +1,242 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ccnj/go-utils/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// 签名身份使用的元数据 key，与 GenCctx2Ctx 一样统一用蛇形
+const (
+	identityUidKey   = "uid"
+	identityRoleKey  = "role"
+	identityScopeKey = "scope"
+	identityTsKey    = "identity_ts"  // 签名时间，unix 秒
+	identityKidKey   = "identity_kid" // 签名密钥 id
+	identitySigKey   = "identity_sig" // base64url(HMAC-SHA256)
+	identityVersion  = "v1"
+)
+
+var (
+	ErrIdentityMissing    = errors.New("middleware: service identity signature missing")
+	ErrIdentityTampered   = errors.New("middleware: service identity signature mismatch")
+	ErrIdentityStale      = errors.New("middleware: service identity expired")
+	ErrIdentityUnknownKey = errors.New("middleware: service identity key not found")
+)
+
+// ServiceIdentityConfig 服务间身份签名的配置，调用方和被调用方使用同一份密钥
+// 调用方用 GrpcUnaryClientIdentity 对 uid、role、scope、request_id、方法名和时间戳签名，
+// 被调用方用 GrpcUnaryServerIdentity 验证，篡改或过期的身份会被拒绝，内网中的任意调用方无法再伪造 uid
+type ServiceIdentityConfig struct {
+	KeyID   string            // 签名使用的密钥 id，必须在 Secrets 中
+	Secrets map[string][]byte // 全部有效密钥，轮换时新旧密钥并存，验证方按 identity_kid 选择
+	MaxAge  time.Duration     // 签名有效期，同时容忍相同幅度的时钟偏差，默认 30 秒
+	// RequireSignature 服务端拒绝未签名的调用，即只接受持有密钥的内部服务调用；
+	// 默认未签名且不带 uid 的调用按匿名放行，带 uid 却未签名的一律拒绝
+	RequireSignature bool
+}
+
+type serviceIdentity struct {
+	keyID            string
+	secrets          map[string][]byte
+	maxAge           time.Duration
+	requireSignature bool
+	now              func() time.Time
+}
+
+func newServiceIdentity(config *ServiceIdentityConfig) *serviceIdentity {
+	if len(config.Secrets) == 0 {
+		panic("middleware: ServiceIdentityConfig.Secrets is required")
+	}
+	if config.KeyID != "" {
+		if _, ok := config.Secrets[config.KeyID]; !ok {
+			panic(fmt.Sprintf("middleware: ServiceIdentityConfig.KeyID %q not found in Secrets", config.KeyID))
+		}
+	}
+	secrets := make(map[string][]byte, len(config.Secrets))
+	for kid, secret := range config.Secrets {
+		if len(secret) == 0 {
+			panic(fmt.Sprintf("middleware: ServiceIdentityConfig.Secrets[%q] is empty", kid))
+		}
+		secrets[kid] = secret
+	}
+	maxAge := config.MaxAge
+	if maxAge <= 0 {
+		maxAge = 30 * time.Second
+	}
+	return &serviceIdentity{
+		keyID:            config.KeyID,
+		secrets:          secrets,
+		maxAge:           maxAge,
+		requireSignature: config.RequireSignature,
+		now:              time.Now,
+	}
+}
+
+// GrpcUnaryClientIdentity 调用方拦截器，用 ctx 中的身份（PrincipalFrom）签名后写入元数据
+// 会覆盖 GenCctx2Ctx 写入的明文 uid；ctx 中没有身份时签名一个匿名身份
+func GrpcUnaryClientIdentity(config *ServiceIdentityConfig) grpc.UnaryClientInterceptor {
+	s := newServiceIdentity(config)
+	if s.keyID == "" {
+		panic("middleware: ServiceIdentityConfig.KeyID is required for signing")
+	}
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(s.sign(ctx, method), method, req, reply, cc, opts...)
+	}
+}
+
+// GrpcStreamClientIdentity 流式调用的身份签名拦截器
+func GrpcStreamClientIdentity(config *ServiceIdentityConfig) grpc.StreamClientInterceptor {
+	s := newServiceIdentity(config)
+	if s.keyID == "" {
+		panic("middleware: ServiceIdentityConfig.KeyID is required for signing")
+	}
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(s.sign(ctx, method), desc, cc, method, opts...)
+	}
+}
+
+// GrpcUnaryServerIdentity 被调用方拦截器，验证签名后把身份存入 ctx，handler 中用 PrincipalFrom(ctx) 读取
+// 验证通过后元数据中的 uid 可信，log 包据此打印的 uid 也不会被伪造
+func GrpcUnaryServerIdentity(config *ServiceIdentityConfig) grpc.UnaryServerInterceptor {
+	s := newServiceIdentity(config)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := s.verify(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// GrpcStreamServerIdentity 流式调用的身份验证拦截器
+func GrpcStreamServerIdentity(config *ServiceIdentityConfig) grpc.StreamServerInterceptor {
+	s := newServiceIdentity(config)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := s.verify(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// sign 签名并写入元数据，request_id 优先取调用方已设置的，其次沿用上游传入的
+func (s *serviceIdentity) sign(ctx context.Context, method string) context.Context {
+	var uid, role, scope string
+	if p, ok := PrincipalFrom(ctx); ok {
+		uid = p.UID
+		role = strconv.FormatInt(int64(p.Role), 10)
+		scope = strings.Join(p.Scopes, " ")
+	}
+	requestId := firstMetadata(ctx, "request_id")
+	ts := strconv.FormatInt(s.now().Unix(), 10)
+	sig := s.mac(s.secrets[s.keyID], method, ts, requestId, uid, role, scope)
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Set("request_id", requestId)
+	md.Set(identityUidKey, uid)
+	md.Set(identityRoleKey, role)
+	md.Set(identityScopeKey, scope)
+	md.Set(identityTsKey, ts)
+	md.Set(identityKidKey, s.keyID)
+	md.Set(identitySigKey, sig)
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// verify 验证签名，失败返回 codes.Unauthenticated
+func (s *serviceIdentity) verify(ctx context.Context, method string) (context.Context, error) {
+	p, err := s.parse(ctx, method)
+	if err != nil {
+		log.Warn(ctx, "服务间身份验证失败，疑似伪造身份", "method", method, "err", err)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if p == nil {
+		return ctx, nil
+	}
+	return ContextWithPrincipal(ctx, p), nil
+}
+
+// parse 返回签名中的身份；未签名的匿名调用返回 (nil, nil)
+func (s *serviceIdentity) parse(ctx context.Context, method string) (*Principal, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	get := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	uid, sig := get(identityUidKey), get(identitySigKey)
+	if sig == "" {
+		if uid != "" || s.requireSignature {
+			return nil, ErrIdentityMissing
+		}
+		return nil, nil
+	}
+
+	secret, ok := s.secrets[get(identityKidKey)]
+	if !ok {
+		return nil, ErrIdentityUnknownKey
+	}
+	ts, role, scope := get(identityTsKey), get(identityRoleKey), get(identityScopeKey)
+	expected := s.mac(secret, method, ts, get("request_id"), uid, role, scope)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return nil, ErrIdentityTampered
+	}
+	signedAt, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, ErrIdentityTampered
+	}
+	if age := s.now().Sub(time.Unix(signedAt, 0)); age > s.maxAge || age < -s.maxAge {
+		return nil, ErrIdentityStale
+	}
+
+	if uid == "" {
+		return nil, nil
+	}
+	p := &Principal{UID: uid, Scopes: strings.Fields(scope)}
+	if role != "" {
+		r, err := strconv.ParseInt(role, 10, 32)
+		if err != nil {
+			return nil, ErrIdentityTampered
+		}
+		p.Role = int32(r)
+	}
+	return p, nil
+}
+
+// mac 对各字段逐行拼接后计算 HMAC-SHA256；元数据值不允许换行，拼接结果无歧义
+// 方法名参与签名，截获的身份不能用于调用其他方法
+func (s *serviceIdentity) mac(secret []byte, method, ts, requestId, uid, role, scope string) string {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(strings.Join([]string{identityVersion, method, ts, requestId, uid, role, scope}, "\n")))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// firstMetadata 先取待发送的元数据，再取收到的元数据，用于在服务间调用链中沿用 request_id
+func firstMetadata(ctx context.Context, key string) string {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		if values := md.Get(key); len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
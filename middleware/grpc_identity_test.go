@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// signedIncoming 调用方签名后，把待发送的元数据转为被调用方收到的元数据
+func signedIncoming(t *testing.T, signer *serviceIdentity, ctx context.Context, method string, mutate func(metadata.MD)) context.Context {
+	t.Helper()
+	md, _ := metadata.FromOutgoingContext(signer.sign(ctx, method))
+	if mutate != nil {
+		mutate(md)
+	}
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+func TestServiceIdentity(t *testing.T) {
+	const method = "/order.OrderService/Get"
+	now := time.Now()
+	signer := newServiceIdentity(&ServiceIdentityConfig{KeyID: "k1", Secrets: map[string][]byte{"k1": []byte("secret-1")}})
+	signer.now = func() time.Time { return now }
+
+	user := ContextWithPrincipal(metadata.AppendToOutgoingContext(context.Background(), "request_id", "req-1"),
+		&Principal{UID: "10086", Role: 2, Scopes: []string{"order:read"}})
+	plainUID := metadata.NewIncomingContext(context.Background(), metadata.Pairs("uid", "10086"))
+
+	tests := []struct {
+		name     string
+		config   ServiceIdentityConfig
+		advance  time.Duration
+		ctx      context.Context
+		wantErr  error
+		wantUID  string
+		wantRole int32
+	}{
+		{"signed user", ServiceIdentityConfig{}, 0, signedIncoming(t, signer, user, method, nil), nil, "10086", 2},
+		{"signed anonymous", ServiceIdentityConfig{}, 0, signedIncoming(t, signer, context.Background(), method, nil), nil, "", 0},
+		{"key rotation keeps old key", ServiceIdentityConfig{Secrets: map[string][]byte{"k2": []byte("secret-2"), "k1": []byte("secret-1")}}, 0,
+			signedIncoming(t, signer, user, method, nil), nil, "10086", 2},
+		{"tampered uid", ServiceIdentityConfig{}, 0, signedIncoming(t, signer, user, method, func(md metadata.MD) { md.Set("uid", "1") }), ErrIdentityTampered, "", 0},
+		{"tampered role", ServiceIdentityConfig{}, 0, signedIncoming(t, signer, user, method, func(md metadata.MD) { md.Set("role", "9") }), ErrIdentityTampered, "", 0},
+		{"tampered request id", ServiceIdentityConfig{}, 0, signedIncoming(t, signer, user, method, func(md metadata.MD) { md.Set("request_id", "req-2") }), ErrIdentityTampered, "", 0},
+		{"replayed on other method", ServiceIdentityConfig{}, 0, signedIncoming(t, signer, user, "/order.OrderService/Delete", nil), ErrIdentityTampered, "", 0},
+		{"stale", ServiceIdentityConfig{}, 31 * time.Second, signedIncoming(t, signer, user, method, nil), ErrIdentityStale, "", 0},
+		{"within max age", ServiceIdentityConfig{MaxAge: time.Minute}, 31 * time.Second, signedIncoming(t, signer, user, method, nil), nil, "10086", 2},
+		{"signed in the future", ServiceIdentityConfig{}, -31 * time.Second, signedIncoming(t, signer, user, method, nil), ErrIdentityStale, "", 0},
+		{"unknown key", ServiceIdentityConfig{Secrets: map[string][]byte{"k2": []byte("secret-2")}}, 0, signedIncoming(t, signer, user, method, nil), ErrIdentityUnknownKey, "", 0},
+		{"wrong secret", ServiceIdentityConfig{Secrets: map[string][]byte{"k1": []byte("other")}}, 0, signedIncoming(t, signer, user, method, nil), ErrIdentityTampered, "", 0},
+		{"unsigned uid", ServiceIdentityConfig{}, 0, plainUID, ErrIdentityMissing, "", 0},
+		{"unsigned anonymous", ServiceIdentityConfig{}, 0, context.Background(), nil, "", 0},
+		{"unsigned anonymous with require signature", ServiceIdentityConfig{RequireSignature: true}, 0, context.Background(), ErrIdentityMissing, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			if config.Secrets == nil {
+				config.Secrets = map[string][]byte{"k1": []byte("secret-1")}
+			}
+			verifier := newServiceIdentity(&config)
+			verifier.now = func() time.Time { return now.Add(tt.advance) }
+
+			p, err := verifier.parse(tt.ctx, method)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			var uid string
+			var role int32
+			if p != nil {
+				uid, role = p.UID, p.Role
+			}
+			if uid != tt.wantUID || role != tt.wantRole {
+				t.Errorf("principal = %q/%d, want %q/%d", uid, role, tt.wantUID, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestGrpcIdentityInterceptors(t *testing.T) {
+	const method = "/order.OrderService/Get"
+	config := &ServiceIdentityConfig{KeyID: "k1", Secrets: map[string][]byte{"k1": []byte("secret-1")}}
+	user := ContextWithPrincipal(context.Background(), &Principal{UID: "10086", Scopes: []string{"order:read"}})
+
+	// 调用方拦截器签名
+	var outgoing metadata.MD
+	client := GrpcUnaryClientIdentity(config)
+	_ = client(user, method, nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		outgoing, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	})
+	if len(outgoing.Get(identitySigKey)) != 1 {
+		t.Fatalf("outgoing metadata has no signature: %v", outgoing)
+	}
+
+	tests := []struct {
+		name     string
+		md       metadata.MD
+		wantCode codes.Code
+		wantUID  string
+	}{
+		{"signed", outgoing, codes.OK, "10086"},
+		{"forged uid", metadata.Pairs("uid", "1"), codes.Unauthenticated, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			var uid string
+			server := GrpcUnaryServerIdentity(config)
+			_, err := server(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				if p, ok := PrincipalFrom(ctx); ok {
+					uid = p.UID
+				}
+				return nil, nil
+			})
+			if status.Code(err) != tt.wantCode || uid != tt.wantUID {
+				t.Errorf("unary: code = %s, uid = %q, want %s, %q", status.Code(err), uid, tt.wantCode, tt.wantUID)
+			}
+
+			uid = ""
+			stream := GrpcStreamServerIdentity(config)
+			err = stream(nil, &fakeServerStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: method}, func(srv interface{}, ss grpc.ServerStream) error {
+				if p, ok := PrincipalFrom(ss.Context()); ok {
+					uid = p.UID
+				}
+				return nil
+			})
+			if status.Code(err) != tt.wantCode || uid != tt.wantUID {
+				t.Errorf("stream: code = %s, uid = %q, want %s, %q", status.Code(err), uid, tt.wantCode, tt.wantUID)
+			}
+		})
+	}
+}
+
+func TestServiceIdentityConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *ServiceIdentityConfig
+	}{
+		{"no secrets", &ServiceIdentityConfig{KeyID: "k1"}},
+		{"key id not in secrets", &ServiceIdentityConfig{KeyID: "k2", Secrets: map[string][]byte{"k1": []byte("s")}}},
+		{"empty secret", &ServiceIdentityConfig{Secrets: map[string][]byte{"k1": nil}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("newServiceIdentity should panic")
+				}
+			}()
+			newServiceIdentity(tt.config)
+		})
+	}
+}
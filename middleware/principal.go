@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/ccnj/go-utils/token"
+	"github.com/gin-gonic/gin"
+)
+
+// PrincipalKey gin.Context 中保存 *Principal 的 key
+const PrincipalKey = "principal"
+
+type principalCtxKey struct{}
+
+// Principal 已认证的身份，由认证中间件写入，下游统一通过 PrincipalFrom 读取
+type Principal struct {
+	UID    string
+	Role   int32
+	Scopes []string      // 授权范围，token 认证时来自 claims.Scope
+	Claims *token.Claims // token 认证时的完整声明
+	Token  string        // token 认证时的原始 token，用于调用下游 gRPC 服务时转发
+	KeyID  string        // API key 认证时的 key id
+}
+
+// SetPrincipal 保存已认证的身份
+// 同时写入 gin.Context 和 ctx.Request.Context()，非 gin 代码拿到 request 的 context 也能读取
+// 为兼容已有代码和 log 包，仍会写入 uid（string）和 role（int64）
+func SetPrincipal(ctx *gin.Context, p *Principal) {
+	ctx.Set(PrincipalKey, p)
+	ctx.Request = ctx.Request.WithContext(ContextWithPrincipal(ctx.Request.Context(), p))
+	ctx.Set("uid", p.UID)
+	ctx.Set("role", int64(p.Role)) // ctx无getInt32方法，所以存int64，取的时候也必须ctx.GetInt64("role") GetInt取不到
+}
+
+// ContextWithPrincipal 把身份存入 context.Context
+func ContextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey{}, p)
+}
+
+// PrincipalFrom 读取已认证的身份，gin.Context 和 context.Context 均可
+// 示例 if p, ok := middleware.PrincipalFrom(ctx); ok { fmt.Println(p.UID) }
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	if gctx, ok := ctx.(*gin.Context); ok {
+		if v, exist := gctx.Get(PrincipalKey); exist {
+			p, ok := v.(*Principal)
+			return p, ok && p != nil
+		}
+		if gctx.Request == nil {
+			return nil, false
+		}
+		ctx = gctx.Request.Context()
+	}
+	p, ok := ctx.Value(principalCtxKey{}).(*Principal)
+	return p, ok && p != nil
+}
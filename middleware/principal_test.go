@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestPrincipalFrom(t *testing.T) {
+	p := &Principal{UID: "10086", Role: 2, Scopes: []string{"order:read"}}
+
+	newGinCtx := func() *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		return ctx
+	}
+	withPrincipal := newGinCtx()
+	SetPrincipal(withPrincipal, p)
+	nilRequest, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		wantOK bool
+	}{
+		{"gin context", withPrincipal, true},
+		{"request context", withPrincipal.Request.Context(), true},
+		{"plain context", ContextWithPrincipal(context.Background(), p), true},
+		{"gin context without principal", newGinCtx(), false},
+		{"gin context without request", nilRequest, false},
+		{"empty context", context.Background(), false},
+		{"nil principal", ContextWithPrincipal(context.Background(), nil), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := PrincipalFrom(tt.ctx)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && got != p {
+				t.Errorf("got %+v, want %+v", got, p)
+			}
+		})
+	}
+
+	// 兼容旧代码读取的 uid、role
+	if withPrincipal.GetString("uid") != "10086" || withPrincipal.GetInt64("role") != 2 {
+		t.Errorf("uid = %q, role = %d", withPrincipal.GetString("uid"), withPrincipal.GetInt64("role"))
+	}
+}
+
+func TestGenCctx2Ctx(t *testing.T) {
+	tests := []struct {
+		name          string
+		principal     *Principal
+		wantUID       string
+		wantPrincipal bool
+	}{
+		{"authenticated", &Principal{UID: "10086", Token: "tok"}, "10086", true},
+		{"anonymous", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cctx context.Context
+			r := gin.New()
+			r.GET("/", GenRequestId2Ctx(), func(ctx *gin.Context) {
+				if tt.principal != nil {
+					SetPrincipal(ctx, tt.principal)
+				}
+			}, GenCctx2Ctx(), func(ctx *gin.Context) {
+				cctx = ctx.MustGet("cctx").(context.Context)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Request-Id", "req-1")
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			md, ok := metadata.FromOutgoingContext(cctx)
+			if !ok {
+				t.Fatal("cctx has no outgoing metadata")
+			}
+			if got := md.Get("uid"); len(got) != 1 || got[0] != tt.wantUID {
+				t.Errorf("uid metadata = %v, want %q", got, tt.wantUID)
+			}
+			if got := md.Get("request_id"); len(got) != 1 || got[0] != "req-1" {
+				t.Errorf("request_id metadata = %v, want req-1", got)
+			}
+			if _, ok := PrincipalFrom(cctx); ok != tt.wantPrincipal {
+				t.Errorf("principal in cctx = %v, want %v", ok, tt.wantPrincipal)
+			}
+		})
+	}
+}
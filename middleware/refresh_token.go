@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ccnj/go-utils/log"
+	"github.com/ccnj/go-utils/token"
+	"github.com/gin-gonic/gin"
+)
+
+type refreshTokenReq struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+}
+
+// RefreshTokenHandler 刷新 token 的接口，挂在不需要验证 access token 的路由上
+// 请求体 {"refresh_token": "..."}，成功返回 token.TokenPair，旧的 refresh token 随即作废
+// 示例 r.POST("/auth/refresh", middleware.RefreshTokenHandler(refreshManager))
+func RefreshTokenHandler(manager *token.RefreshManager) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req refreshTokenReq
+		if err := ctx.ShouldBind(&req); err != nil || req.RefreshToken == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"errCode": 400,
+				"errMsg":  "缺少 refresh_token",
+			})
+			return
+		}
+
+		pair, err := manager.Rotate(ctx, req.RefreshToken)
+		if err != nil {
+			var errMsg string
+			switch {
+			case errors.Is(err, token.ErrRefreshTokenExpired):
+				errMsg = "登录已过期，请重新登录"
+			case errors.Is(err, token.ErrRefreshTokenReused):
+				errMsg = "登录状态异常，请重新登录"
+			case errors.Is(err, token.ErrRefreshTokenInvalid):
+				errMsg = "身份认证失败，请重新登录"
+			default:
+				log.Error(ctx, "刷新 token 失败", "err", err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{
+					"errCode": 500,
+					"errMsg":  "服务繁忙，请稍后再试",
+				})
+				return
+			}
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"errCode": 401,
+				"errMsg":  errMsg,
+			})
+			return
+		}
+		ctx.JSON(http.StatusOK, pair)
+	}
+}
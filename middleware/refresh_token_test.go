@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ccnj/go-utils/token"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// brokenRefreshStore 模拟存储故障
+type brokenRefreshStore struct {
+	*token.MemoryRefreshStore
+	broken bool
+}
+
+func (s *brokenRefreshStore) Get(ctx context.Context, id string) (*token.RefreshRecord, error) {
+	if s.broken {
+		return nil, errors.New("redis: connection refused")
+	}
+	return s.MemoryRefreshStore.Get(ctx, id)
+}
+
+func TestRefreshTokenHandler(t *testing.T) {
+	iss, err := token.NewIssuer(&token.IssuerConfig{SigningMethod: jwt.SigningMethodHS256, SigningKey: []byte("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &brokenRefreshStore{MemoryRefreshStore: token.NewMemoryRefreshStore()}
+	manager, err := token.NewRefreshManager(&token.RefreshConfig{Issuer: iss, Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	login, err := manager.Login(context.Background(), "10086", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := manager.Login(context.Background(), "10087", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.POST("/auth/refresh", RefreshTokenHandler(manager))
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		broken      bool
+		wantStatus  int
+		wantMsg     string
+	}{
+		{"rotate", `{"refresh_token":"` + login.RefreshToken + `"}`, "application/json", false, http.StatusOK, ""},
+		{"reuse detected", `{"refresh_token":"` + login.RefreshToken + `"}`, "application/json", false, http.StatusUnauthorized, "登录状态异常，请重新登录"},
+		{"unknown token", `{"refresh_token":"nope"}`, "application/json", false, http.StatusUnauthorized, "身份认证失败，请重新登录"},
+		{"form body", "refresh_token=" + other.RefreshToken, "application/x-www-form-urlencoded", false, http.StatusOK, ""},
+		{"missing token", `{}`, "application/json", false, http.StatusBadRequest, "缺少 refresh_token"},
+		{"store failure", `{"refresh_token":"nope"}`, "application/json", true, http.StatusInternalServerError, "服务繁忙，请稍后再试"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.broken = tt.broken
+			req := httptest.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			var resp map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantMsg != "" && resp["errMsg"] != tt.wantMsg {
+				t.Errorf("errMsg = %v, want %q", resp["errMsg"], tt.wantMsg)
+			}
+			if tt.wantStatus == http.StatusOK && (resp["access_token"] == "" || resp["refresh_token"] == "") {
+				t.Errorf("missing tokens in %v", resp)
+			}
+		})
+	}
+}
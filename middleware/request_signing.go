@@ -0,0 +1,286 @@
+package middleware
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccnj/go-utils/log"
+	"github.com/ccnj/go-utils/rand/cryptorand"
+	"github.com/gin-gonic/gin"
+)
+
+// 请求签名使用的请求头
+const (
+	SignatureHeader          = "X-Signature"           // hex(HMAC-SHA256)
+	SignatureKeyIDHeader     = "X-Signature-Key-Id"    // 签名密钥 id，验证方据此选择密钥，用于密钥轮换
+	SignatureTimestampHeader = "X-Signature-Timestamp" // unix 秒
+	SignatureNonceHeader     = "X-Signature-Nonce"     // 每个请求唯一的随机串，防重放
+
+	signatureAlgorithm = "HMAC-SHA256"
+	signatureNonceLen  = 24
+)
+
+// SignatureKeyIDKey 验证通过后 gin.Context 中保存签名密钥 id 的 key，可据此区分是哪个合作方
+const SignatureKeyIDKey = "signature_key_id"
+
+// RequestSigner 对发出的 http 请求签名，签名覆盖方法、路径、查询参数、时间戳、nonce、指定的请求头和请求体的 sha256
+// 示例
+//
+//	signer := middleware.NewRequestSigner("partner-2024", secret, "Content-Type")
+//	req, _ := http.NewRequest("POST", url, bytes.NewReader(body))
+//	req.Header.Set("Content-Type", "application/json")
+//	err := signer.Sign(req)
+type RequestSigner struct {
+	keyID         string
+	secret        []byte
+	signedHeaders []string
+	now           func() time.Time
+}
+
+// NewRequestSigner signedHeaders 须与验证方 SignatureConfig.SignedHeaders 一致，"Host" 取 req.Host
+func NewRequestSigner(keyID string, secret []byte, signedHeaders ...string) *RequestSigner {
+	return &RequestSigner{keyID: keyID, secret: secret, signedHeaders: signedHeaders, now: time.Now}
+}
+
+// Sign 计算签名并写入请求头；会读取请求体，读取后重新设置 req.Body，调用方可照常发送
+func (s *RequestSigner) Sign(req *http.Request) error {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	nonce, err := cryptorand.GenCryptoRandStr(signatureNonceLen)
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(s.now().Unix(), 10)
+	req.Header.Set(SignatureKeyIDHeader, s.keyID)
+	req.Header.Set(SignatureTimestampHeader, ts)
+	req.Header.Set(SignatureNonceHeader, nonce)
+	req.Header.Set(SignatureHeader, computeSignature(s.secret, req, s.signedHeaders, ts, nonce, body))
+	return nil
+}
+
+// canonicalRequest 逐行拼接参与签名的内容
+func canonicalRequest(req *http.Request, signedHeaders []string, ts, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	var b strings.Builder
+	b.WriteString(signatureAlgorithm + "\n")
+	b.WriteString(req.Method + "\n")
+	b.WriteString(req.URL.EscapedPath() + "\n")
+	b.WriteString(req.URL.Query().Encode() + "\n") // Encode 按 key 排序，参数顺序不影响签名
+	b.WriteString(ts + "\n")
+	b.WriteString(nonce + "\n")
+	for _, h := range signedHeaders {
+		value := req.Header.Get(h)
+		if strings.EqualFold(h, "Host") {
+			value = req.Host
+		}
+		b.WriteString(strings.ToLower(h) + ":" + strings.TrimSpace(value) + "\n")
+	}
+	b.WriteString(hex.EncodeToString(bodyHash[:]))
+	return b.String()
+}
+
+func computeSignature(secret []byte, req *http.Request, signedHeaders []string, ts, nonce string, body []byte) string {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(canonicalRequest(req, signedHeaders, ts, nonce, body)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// NonceStore 记录已使用过的 nonce，多实例部署时需用 Redis 等共享存储实现
+type NonceStore interface {
+	// CheckAndStore 原子地记录 nonce，已存在时返回 false
+	CheckAndStore(ctx context.Context, nonce string, ttl time.Duration) (fresh bool, err error)
+}
+
+// SignatureConfig VerifySignature 的配置
+type SignatureConfig struct {
+	Secrets       map[string][]byte // key id 到密钥，轮换时新旧密钥并存，必填
+	SignedHeaders []string          // 须参与签名的请求头，与调用方 NewRequestSigner 一致
+	MaxSkew       time.Duration     // 时间戳允许的最大偏差，默认 5 分钟
+	Nonces        NonceStore        // 默认为单实例内存存储
+	MaxBodySize   int64             // 读取请求体的上限，默认 10MB，超过返回 413
+	Skip          *RouteMatcher     // 不需要验证的路由
+	// Errors 失败响应的配置，为空时使用 DefaultAuthErrors，WWW-Authenticate 的认证方案为 Signature
+	// 未知 key id 与签名错误的原因同为 FailureInvalidSignature，不向调用方透露 key id 是否存在
+	Errors *AuthErrorConfig
+}
+
+// VerifySignature 验证 RequestSigner 签名的请求，用于 webhook 回调和合作方服务端调用
+// 时间戳超出窗口、nonce 重复、签名不符均返回 401，请求体过大返回 413；验证通过后请求体可被后续 handler 正常读取
+// 示例 r.POST("/webhook/pay", middleware.VerifySignature(&middleware.SignatureConfig{Secrets: secrets}), handler)
+func VerifySignature(config *SignatureConfig) gin.HandlerFunc {
+	if len(config.Secrets) == 0 {
+		panic("middleware: SignatureConfig.Secrets is required")
+	}
+	secrets := make(map[string][]byte, len(config.Secrets))
+	for kid, secret := range config.Secrets {
+		secrets[kid] = secret
+	}
+	maxSkew := config.MaxSkew
+	if maxSkew <= 0 {
+		maxSkew = 5 * time.Minute
+	}
+	nonces := config.Nonces
+	if nonces == nil {
+		nonces = NewMemoryNonceStore()
+	}
+	maxBodySize := config.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = 10 << 20
+	}
+	signedHeaders := config.SignedHeaders
+	errs := config.Errors
+	if errs == nil {
+		defaults := *DefaultAuthErrors
+		if defaults.Scheme == "" {
+			defaults.Scheme = "Signature"
+		}
+		errs = &defaults
+	}
+
+	// detail 只写日志，响应中只有 reason 对应的提示语
+	reject := func(ctx *gin.Context, reason AuthFailure, detail string, kv ...interface{}) {
+		log.Info(ctx, "请求签名验证失败", append([]interface{}{"reason", detail, "path", ctx.Request.URL.Path}, kv...)...)
+		errs.abort(ctx, reason, nil)
+	}
+
+	return func(ctx *gin.Context) {
+		if _, ok := config.Skip.Match(ctx); ok {
+			return
+		}
+		req := ctx.Request
+		keyID := req.Header.Get(SignatureKeyIDHeader)
+		sig := req.Header.Get(SignatureHeader)
+		ts := req.Header.Get(SignatureTimestampHeader)
+		nonce := req.Header.Get(SignatureNonceHeader)
+		if sig == "" || ts == "" || nonce == "" {
+			reject(ctx, FailureMissingToken, "缺少签名")
+			return
+		}
+		secret, ok := secrets[keyID]
+		if !ok {
+			reject(ctx, FailureInvalidSignature, "签名密钥不存在", "key_id", keyID)
+			return
+		}
+		signedAt, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			reject(ctx, FailureMalformedToken, "签名时间戳格式错误")
+			return
+		}
+		if skew := time.Since(time.Unix(signedAt, 0)); skew > maxSkew || skew < -maxSkew {
+			reject(ctx, FailureExpiredToken, "请求已过期", "key_id", keyID, "skew", skew.String())
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(req.Body, maxBodySize+1))
+		if err != nil {
+			reject(ctx, FailureInvalidToken, "读取请求体失败", "err", err)
+			return
+		}
+		if int64(len(body)) > maxBodySize {
+			reject(ctx, FailureRequestTooLarge, "请求体过大", "key_id", keyID)
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		expected := computeSignature(secret, req, signedHeaders, ts, nonce, body)
+		if !hmac.Equal([]byte(strings.ToLower(sig)), []byte(expected)) {
+			reject(ctx, FailureInvalidSignature, "签名错误", "key_id", keyID)
+			return
+		}
+		// 签名通过后再记录 nonce，未签名的请求无法占满 nonce 存储
+		fresh, err := nonces.CheckAndStore(ctx, keyID+":"+nonce, 2*maxSkew)
+		if err != nil {
+			log.Error(ctx, "记录请求 nonce 失败", "err", err)
+			errs.abort(ctx, FailureUnavailable, err)
+			return
+		}
+		if !fresh {
+			reject(ctx, FailureInvalidToken, "重复的请求", "key_id", keyID)
+			return
+		}
+		ctx.Set(SignatureKeyIDKey, keyID)
+	}
+}
+
+// ErrNonceStoreFull 内存 nonce 存储达到上限
+var ErrNonceStoreFull = errors.New("middleware: nonce store is full")
+
+// MemoryNonceStore 内存实现的 NonceStore，用于单实例服务和测试
+// 按过期时间组织成小顶堆，每次写入前只弹出已过期的堆顶，清理代价均摊到每次写入 O(log n)，
+// 不会在持锁期间遍历全部 nonce
+type MemoryNonceStore struct {
+	mu       sync.Mutex
+	nonces   map[string]time.Time // nonce 到过期时间
+	expiry   nonceHeap            // 与 nonces 一一对应，按过期时间排序
+	capacity int
+	now      func() time.Time
+}
+
+// NewMemoryNonceStore 最多保存 100 万个未过期的 nonce，超过时拒绝新请求而不是放过重放
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time), capacity: 1000000, now: time.Now}
+}
+
+func (s *MemoryNonceStore) CheckAndStore(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.evictExpired(now)
+	if _, ok := s.nonces[nonce]; ok {
+		return false, nil
+	}
+	if len(s.nonces) >= s.capacity {
+		return false, ErrNonceStoreFull
+	}
+	expiresAt := now.Add(ttl)
+	s.nonces[nonce] = expiresAt
+	heap.Push(&s.expiry, nonceEntry{nonce: nonce, expiresAt: expiresAt})
+	return true, nil
+}
+
+// evictExpired 弹出已过期的堆顶，调用方需持有锁
+func (s *MemoryNonceStore) evictExpired(now time.Time) {
+	for len(s.expiry) > 0 && !now.Before(s.expiry[0].expiresAt) {
+		e := heap.Pop(&s.expiry).(nonceEntry)
+		delete(s.nonces, e.nonce)
+	}
+}
+
+type nonceEntry struct {
+	nonce     string
+	expiresAt time.Time
+}
+
+// nonceHeap 实现 heap.Interface，堆顶为最早过期的 nonce
+type nonceHeap []nonceEntry
+
+func (h nonceHeap) Len() int            { return len(h) }
+func (h nonceHeap) Less(i, j int) bool  { return h[i].expiresAt.Before(h[j].expiresAt) }
+func (h nonceHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *nonceHeap) Push(x interface{}) { *h = append(*h, x.(nonceEntry)) }
+func (h *nonceHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	*h = old[:n-1]
+	return e
+}
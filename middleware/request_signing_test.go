@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secrets := map[string][]byte{"partner-1": []byte("secret-1"), "partner-2": []byte("secret-2")}
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/webhook/pay?b=2&a=1", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+	sign := func(keyID string, secret []byte, req *http.Request, now time.Time) *http.Request {
+		signer := NewRequestSigner(keyID, secret, "Content-Type")
+		signer.now = func() time.Time { return now }
+		if err := signer.Sign(req); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	now := time.Now()
+	replayed := sign("partner-1", secrets["partner-1"], newRequest(`{"id":1}`), now)
+
+	tests := []struct {
+		name       string
+		req        func() *http.Request
+		wantStatus int
+		wantReason AuthFailure
+	}{
+		{"valid", func() *http.Request { return sign("partner-1", secrets["partner-1"], newRequest(`{"id":1}`), now) }, http.StatusOK, ""},
+		{"rotated key", func() *http.Request { return sign("partner-2", secrets["partner-2"], newRequest(`{"id":1}`), now) }, http.StatusOK, ""},
+		{"query order does not matter", func() *http.Request {
+			req := sign("partner-1", secrets["partner-1"], newRequest(`{}`), now)
+			req.URL.RawQuery = "a=1&b=2"
+			return req
+		}, http.StatusOK, ""},
+		{"tampered body", func() *http.Request {
+			req := sign("partner-1", secrets["partner-1"], newRequest(`{"id":1}`), now)
+			req.Body = io.NopCloser(strings.NewReader(`{"id":2}`))
+			return req
+		}, http.StatusUnauthorized, FailureInvalidSignature},
+		{"tampered query", func() *http.Request {
+			req := sign("partner-1", secrets["partner-1"], newRequest(`{}`), now)
+			req.URL.RawQuery = "a=1&b=3"
+			return req
+		}, http.StatusUnauthorized, FailureInvalidSignature},
+		{"tampered signed header", func() *http.Request {
+			req := sign("partner-1", secrets["partner-1"], newRequest(`{}`), now)
+			req.Header.Set("Content-Type", "text/plain")
+			return req
+		}, http.StatusUnauthorized, FailureInvalidSignature},
+		{"wrong secret", func() *http.Request { return sign("partner-1", []byte("other"), newRequest(`{}`), now) }, http.StatusUnauthorized, FailureInvalidSignature},
+		{"unknown key id", func() *http.Request { return sign("partner-3", secrets["partner-1"], newRequest(`{}`), now) }, http.StatusUnauthorized, FailureInvalidSignature},
+		{"expired timestamp", func() *http.Request {
+			return sign("partner-1", secrets["partner-1"], newRequest(`{}`), now.Add(-6*time.Minute))
+		}, http.StatusUnauthorized, FailureExpiredToken},
+		{"future timestamp", func() *http.Request {
+			return sign("partner-1", secrets["partner-1"], newRequest(`{}`), now.Add(6*time.Minute))
+		}, http.StatusUnauthorized, FailureExpiredToken},
+		{"missing signature", func() *http.Request { return newRequest(`{}`) }, http.StatusUnauthorized, FailureMissingToken},
+		{"body too large", func() *http.Request {
+			return sign("partner-1", secrets["partner-1"], newRequest(strings.Repeat("x", 65)), now)
+		}, http.StatusRequestEntityTooLarge, FailureRequestTooLarge},
+		{"first use of a nonce", func() *http.Request { return replayed }, http.StatusOK, ""},
+		{"replayed nonce", func() *http.Request {
+			req := newRequest(`{"id":1}`)
+			req.Header = replayed.Header.Clone()
+			return req
+		}, http.StatusUnauthorized, FailureInvalidToken},
+	}
+
+	// 所有用例共用一个 nonce 存储，重放的用例依赖前一个用例
+	var reason AuthFailure
+	errs := &AuthErrorConfig{Renderer: func(ctx *gin.Context, e *AuthError) {
+		reason = e.Reason
+		ctx.JSON(e.Status, gin.H{"errCode": e.Code, "errMsg": e.Message})
+	}}
+	mw := VerifySignature(&SignatureConfig{Secrets: secrets, SignedHeaders: []string{"Content-Type"}, MaxBodySize: 64, Errors: errs})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body string
+			reason = ""
+			r := gin.New()
+			r.POST("/webhook/pay", mw, func(ctx *gin.Context) {
+				b, _ := io.ReadAll(ctx.Request.Body)
+				body = string(b)
+				ctx.String(http.StatusOK, ctx.GetString(SignatureKeyIDKey))
+			})
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, tt.req())
+			if w.Code != tt.wantStatus || reason != tt.wantReason {
+				t.Fatalf("status = %d, reason = %q, want %d, %q, body %s", w.Code, reason, tt.wantStatus, tt.wantReason, w.Body)
+			}
+			if w.Code == http.StatusOK && (body == "" || !strings.HasPrefix(w.Body.String(), "partner-")) {
+				t.Errorf("handler body = %q, key id = %q", body, w.Body)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureDefaultErrors(t *testing.T) {
+	secrets := map[string][]byte{"partner-1": []byte("secret-1")}
+	mw := VerifySignature(&SignatureConfig{Secrets: secrets, MaxBodySize: 64})
+	send := func(keyID string, secret []byte, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+		if err := NewRequestSigner(keyID, secret).Sign(req); err != nil {
+			t.Fatal(err)
+		}
+		r := gin.New()
+		r.POST("/webhook", mw)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// key id 不存在与签名错误的响应完全一致
+	unknown := send("partner-2", secrets["partner-1"], "{}")
+	wrong := send("partner-1", []byte("other"), "{}")
+	if unknown.Code != http.StatusUnauthorized || unknown.Body.String() != wrong.Body.String() ||
+		unknown.Header().Get("WWW-Authenticate") != wrong.Header().Get("WWW-Authenticate") {
+		t.Errorf("unknown key id = %d %s, wrong secret = %d %s", unknown.Code, unknown.Body, wrong.Code, wrong.Body)
+	}
+	if got := unknown.Header().Get("WWW-Authenticate"); !strings.HasPrefix(got, "Signature ") {
+		t.Errorf("WWW-Authenticate = %q, want Signature scheme", got)
+	}
+
+	tooLarge := send("partner-1", secrets["partner-1"], strings.Repeat("x", 65))
+	if tooLarge.Code != http.StatusRequestEntityTooLarge || !strings.Contains(tooLarge.Body.String(), `"errCode":413`) ||
+		tooLarge.Header().Get("WWW-Authenticate") != "" {
+		t.Errorf("body too large = %d %s, WWW-Authenticate %q", tooLarge.Code, tooLarge.Body, tooLarge.Header().Get("WWW-Authenticate"))
+	}
+}
+
+// brokenNonceStore 模拟 Redis 故障
+type brokenNonceStore struct{}
+
+func (brokenNonceStore) CheckAndStore(context.Context, string, time.Duration) (bool, error) {
+	return false, errors.New("redis: connection refused")
+}
+
+func TestVerifySignatureNonceStoreFailure(t *testing.T) {
+	secret := []byte("secret-1")
+	r := gin.New()
+	r.POST("/webhook", VerifySignature(&SignatureConfig{Secrets: map[string][]byte{"k": secret}, Nonces: brokenNonceStore{}}))
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader("{}"))
+	if err := NewRequestSigner("k", secret).Sign(req); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), `"errCode":500`) {
+		t.Errorf("status = %d, body %s, want 500", w.Code, w.Body)
+	}
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewMemoryNonceStore()
+	store.capacity = 2
+	store.now = func() time.Time { return now }
+
+	tests := []struct {
+		name      string
+		advance   time.Duration
+		nonce     string
+		ttl       time.Duration
+		wantFresh bool
+		wantErr   error
+	}{
+		{"first use", 0, "a", time.Minute, true, nil},
+		{"replay", time.Second, "a", time.Minute, false, nil},
+		{"second nonce", 0, "b", 2 * time.Minute, true, nil},
+		{"full", 0, "c", time.Minute, false, ErrNonceStoreFull},
+		{"expired nonce frees capacity", time.Minute, "c", time.Minute, true, nil},
+		{"full with b and c", 0, "a", time.Minute, false, ErrNonceStoreFull},
+		{"b still stored", 0, "b", time.Minute, false, nil},
+		{"expired nonce can be reused", 2 * time.Minute, "a", time.Minute, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			fresh, err := store.CheckAndStore(ctx, tt.nonce, tt.ttl)
+			if fresh != tt.wantFresh || !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckAndStore(%q) = %v, %v, want %v, %v", tt.nonce, fresh, err, tt.wantFresh, tt.wantErr)
+			}
+			if len(store.nonces) != len(store.expiry) {
+				t.Errorf("map has %d nonces, heap has %d", len(store.nonces), len(store.expiry))
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type routeKind int
+
+const (
+	routeExact routeKind = iota
+	routePrefix
+	routeGlob
+	routeTemplate
+)
+
+// RouteRule 一条路由匹配规则，由 Exact、Prefix、Glob、Route 创建
+type RouteRule struct {
+	kind    routeKind
+	pattern string
+	methods []string
+}
+
+// Exact 路径完全相等，methods 为空表示任意方法
+// 示例 middleware.Exact("/api/login", "POST")
+func Exact(p string, methods ...string) RouteRule {
+	return RouteRule{kind: routeExact, pattern: p, methods: methods}
+}
+
+// Prefix 按路径段匹配前缀："/api/login" 匹配 "/api/login"、"/api/login/sms"，不匹配 "/api/login-admin"
+func Prefix(prefix string, methods ...string) RouteRule {
+	return RouteRule{kind: routePrefix, pattern: prefix, methods: methods}
+}
+
+// Glob 通配符匹配：* 匹配一个路径段内的任意字符，** 匹配任意多个路径段
+// 示例 middleware.Glob("/api/*/public/**")
+func Glob(pattern string, methods ...string) RouteRule {
+	return RouteRule{kind: routeGlob, pattern: pattern, methods: methods}
+}
+
+// Route 按 gin 注册的路由模板（c.FullPath()）匹配
+// 示例 middleware.Route("/api/articles/:id", "GET")
+func Route(fullPath string, methods ...string) RouteRule {
+	return RouteRule{kind: routeTemplate, pattern: fullPath, methods: methods}
+}
+
+// String 用于日志和排查是哪条规则命中，如 "prefix /api/login [POST]"
+func (r RouteRule) String() string {
+	kind := [...]string{"exact", "prefix", "glob", "route"}[r.kind]
+	s := kind + " " + r.pattern
+	if len(r.methods) > 0 {
+		s += " [" + strings.Join(r.methods, ",") + "]"
+	}
+	return s
+}
+
+// MatchRequest method 为请求方法，urlPath 为请求路径，fullPath 为 gin 路由模板（未匹配到路由时为空）
+func (r RouteRule) MatchRequest(method, urlPath, fullPath string) bool {
+	if len(r.methods) > 0 {
+		allowed := false
+		for _, m := range r.methods {
+			if strings.EqualFold(m, method) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	switch r.kind {
+	case routeExact:
+		return urlPath == r.pattern
+	case routePrefix:
+		prefix := strings.TrimSuffix(r.pattern, "/")
+		return urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/")
+	case routeGlob:
+		return globMatch(strings.Split(r.pattern, "/"), strings.Split(urlPath, "/"))
+	case routeTemplate:
+		return fullPath != "" && fullPath == r.pattern
+	}
+	return false
+}
+
+// globMatch 逐段匹配，** 可匹配零个或多个路径段
+func globMatch(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(segments); i++ {
+				if globMatch(rest, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// RouteMatcher 按顺序尝试多条规则
+type RouteMatcher struct {
+	rules []RouteRule
+}
+
+// NewRouteMatcher 创建路由匹配器
+// 示例 middleware.NewRouteMatcher(middleware.Exact("/api/login", "POST"), middleware.Prefix("/api/public"))
+func NewRouteMatcher(rules ...RouteRule) *RouteMatcher {
+	return &RouteMatcher{rules: rules}
+}
+
+// Add 追加规则
+func (m *RouteMatcher) Add(rules ...RouteRule) *RouteMatcher {
+	m.rules = append(m.rules, rules...)
+	return m
+}
+
+// Match 返回第一条命中的规则，可用于测试某个请求会被哪条规则跳过
+func (m *RouteMatcher) Match(ctx *gin.Context) (RouteRule, bool) {
+	if m == nil {
+		return RouteRule{}, false
+	}
+	return m.MatchRequest(ctx.Request.Method, ctx.Request.URL.Path, ctx.FullPath())
+}
+
+// MatchRequest 同 Match，不依赖 gin.Context
+func (m *RouteMatcher) MatchRequest(method, urlPath, fullPath string) (RouteRule, bool) {
+	if m == nil {
+		return RouteRule{}, false
+	}
+	for _, r := range m.rules {
+		if r.MatchRequest(method, urlPath, fullPath) {
+			return r, true
+		}
+	}
+	return RouteRule{}, false
+}
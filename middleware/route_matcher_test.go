@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRouteRuleMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     RouteRule
+		method   string
+		urlPath  string
+		fullPath string
+		want     bool
+	}{
+		{"exact", Exact("/api/login"), "POST", "/api/login", "", true},
+		{"exact rejects sub path", Exact("/api/login"), "POST", "/api/login/sms", "", false},
+		{"exact with method", Exact("/api/login", "POST"), "post", "/api/login", "", true},
+		{"exact wrong method", Exact("/api/login", "POST"), "GET", "/api/login", "", false},
+		{"prefix itself", Prefix("/api/login"), "GET", "/api/login", "", true},
+		{"prefix sub path", Prefix("/api/login"), "GET", "/api/login/sms", "", true},
+		{"prefix with trailing slash", Prefix("/api/public/"), "GET", "/api/public/a", "", true},
+		{"prefix respects segments", Prefix("/api/login"), "GET", "/api/login-admin", "", false},
+		{"glob single segment", Glob("/api/*/public"), "GET", "/api/v1/public", "", true},
+		{"glob star stays in segment", Glob("/api/*/public"), "GET", "/api/v1/x/public", "", false},
+		{"glob double star", Glob("/api/**/public"), "GET", "/api/v1/x/public", "", true},
+		{"glob double star matches zero segments", Glob("/api/**/public"), "GET", "/api/public", "", true},
+		{"glob trailing double star", Glob("/static/**"), "GET", "/static/js/app.js", "", true},
+		{"glob partial segment", Glob("/files/*.png"), "GET", "/files/a.png", "", true},
+		{"glob partial segment mismatch", Glob("/files/*.png"), "GET", "/files/a.jpg", "", false},
+		{"route template", Route("/api/articles/:id", "GET"), "GET", "/api/articles/42", "/api/articles/:id", true},
+		{"route template needs gin route", Route("/api/articles/:id"), "GET", "/api/articles/42", "", false},
+		{"route template other route", Route("/api/articles/:id"), "GET", "/api/users/42", "/api/users/:id", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.MatchRequest(tt.method, tt.urlPath, tt.fullPath); got != tt.want {
+				t.Errorf("%s MatchRequest(%s %s) = %v, want %v", tt.rule, tt.method, tt.urlPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteMatcherFirstMatch(t *testing.T) {
+	m := NewRouteMatcher(Exact("/api/login", "POST")).Add(Prefix("/api/public"), Glob("/api/**"))
+	tests := []struct {
+		method, path string
+		want         string
+		wantOK       bool
+	}{
+		{"POST", "/api/login", "exact /api/login [POST]", true},
+		{"GET", "/api/public/news", "prefix /api/public", true},
+		{"GET", "/api/orders", "glob /api/**", true},
+		{"GET", "/health", "", false},
+	}
+	for _, tt := range tests {
+		rule, ok := m.MatchRequest(tt.method, tt.path, "")
+		if ok != tt.wantOK || (ok && rule.String() != tt.want) {
+			t.Errorf("MatchRequest(%s %s) = %q, %v, want %q, %v", tt.method, tt.path, rule, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	var nilMatcher *RouteMatcher
+	if _, ok := nilMatcher.MatchRequest("GET", "/", ""); ok {
+		t.Error("nil matcher should match nothing")
+	}
+}
+
+func TestValidateTokenSkip(t *testing.T) {
+	mw := ValidateTokenWithConfig(&ValidateTokenConfig{
+		VerifierConfig:  hmacVerifierConfig(),
+		SkipPathsPrefix: []string{"/api/login"},
+		Skip:            NewRouteMatcher(Route("/api/articles/:id", "GET")),
+	})
+	tests := []struct {
+		name       string
+		route      string
+		path       string
+		wantStatus int
+	}{
+		{"prefix skipped", "/api/login/sms", "/api/login/sms", http.StatusOK},
+		{"similar prefix not skipped", "/api/login-admin", "/api/login-admin", http.StatusUnauthorized},
+		{"route template skipped", "/api/articles/:id", "/api/articles/42", http.StatusOK},
+		{"other route not skipped", "/api/orders/:id", "/api/orders/42", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := runAuth(t, tt.route, tt.path, nil, mw); res.status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", res.status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrMalformedAuthHeader 请求带了授权头，但 scheme 不符或 token 为空
+var ErrMalformedAuthHeader = errors.New("middleware: malformed authorization header")
+
+// TokenExtractor 从请求中取出 token
+// 请求中没有 token 时返回 ("", nil)；有但格式错误时返回 ErrMalformedAuthHeader 等错误
+type TokenExtractor interface {
+	Extract(ctx *gin.Context) (string, error)
+}
+
+// TokenExtractorFunc 函数形式的 TokenExtractor
+type TokenExtractorFunc func(ctx *gin.Context) (string, error)
+
+func (f TokenExtractorFunc) Extract(ctx *gin.Context) (string, error) {
+	return f(ctx)
+}
+
+// DefaultTokenExtractors ValidateTokenConfig.Extractors 为空时使用，只读取 "Authorization: Bearer ..."
+var DefaultTokenExtractors = []TokenExtractor{FromAuthHeader("Authorization", "Bearer")}
+
+// FromAuthHeader 从带 scheme 的请求头读取，scheme 不区分大小写，如 "bearer xxx" 也能识别
+// 示例 middleware.FromAuthHeader("Authorization", "Bearer")
+func FromAuthHeader(header, scheme string) TokenExtractor {
+	return TokenExtractorFunc(func(ctx *gin.Context) (string, error) {
+		return parseAuthScheme(ctx.GetHeader(header), scheme)
+	})
+}
+
+// parseAuthScheme 解析 "<scheme> <token>"，scheme 不区分大小写；value 为空时返回 ("", nil)
+func parseAuthScheme(value, scheme string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	if len(value) <= len(scheme) || !strings.EqualFold(value[:len(scheme)], scheme) || value[len(scheme)] != ' ' {
+		return "", ErrMalformedAuthHeader
+	}
+	tokenStr := strings.TrimSpace(value[len(scheme):])
+	if tokenStr == "" {
+		return "", ErrMalformedAuthHeader
+	}
+	return tokenStr, nil
+}
+
+// FromHeader 从请求头直接读取 token，不带 scheme，如 "x-token: xxx"
+func FromHeader(header string) TokenExtractor {
+	return TokenExtractorFunc(func(ctx *gin.Context) (string, error) {
+		return strings.TrimSpace(ctx.GetHeader(header)), nil
+	})
+}
+
+// FromCookie 从 cookie 读取，用于 web 端把 token 存在 HttpOnly cookie 的场景
+// 注意：基于 cookie 的认证需配合 CSRF 防护
+func FromCookie(name string) TokenExtractor {
+	return TokenExtractorFunc(func(ctx *gin.Context) (string, error) {
+		value, err := ctx.Cookie(name)
+		if err != nil {
+			return "", nil
+		}
+		return value, nil
+	})
+}
+
+// FromQuery 从查询参数读取，用于无法设置请求头的 WebSocket 握手
+// 注意：URL 会出现在访问日志、代理日志中，只应对必要的路由启用
+func FromQuery(param string) TokenExtractor {
+	return TokenExtractorFunc(func(ctx *gin.Context) (string, error) {
+		return ctx.Query(param), nil
+	})
+}
+
+// extractToken 按顺序尝试，返回第一个取到的 token
+// 全部没有取到时，若有提取器报错则返回第一个错误，否则返回 ("", nil) 表示未携带 token
+func extractToken(ctx *gin.Context, extractors []TokenExtractor) (string, error) {
+	var firstErr error
+	for _, e := range extractors {
+		tokenStr, err := e.Extract(ctx)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if tokenStr != "" {
+			return tokenStr, nil
+		}
+	}
+	return "", firstErr
+}
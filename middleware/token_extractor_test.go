@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseAuthScheme(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"bearer", "Bearer abc", "abc", false},
+		{"scheme case insensitive", "bearer abc", "abc", false},
+		{"extra spaces", "  Bearer   abc  ", "abc", false},
+		{"empty", "", "", false},
+		{"only spaces", "   ", "", false},
+		{"other scheme", "Basic abc", "", true},
+		{"scheme without token", "Bearer", "", true},
+		{"scheme with blank token", "Bearer    ", "", true},
+		{"no space after scheme", "Bearerabc", "", true},
+		{"token without scheme", "abc.def.ghi", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAuthScheme(tt.value, "Bearer")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrMalformedAuthHeader) {
+				t.Errorf("err = %v, want ErrMalformedAuthHeader", err)
+			}
+			if got != tt.want {
+				t.Errorf("token = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractToken(t *testing.T) {
+	extractors := []TokenExtractor{
+		FromAuthHeader("Authorization", "Bearer"),
+		FromHeader("x-token"),
+		FromCookie("token"),
+		FromQuery("access_token"),
+	}
+	tests := []struct {
+		name    string
+		target  string
+		setup   func(*http.Request)
+		want    string
+		wantErr bool
+	}{
+		{"auth header", "/", func(r *http.Request) { r.Header.Set("Authorization", "Bearer a") }, "a", false},
+		{"plain header", "/", func(r *http.Request) { r.Header.Set("x-token", " b ") }, "b", false},
+		{"cookie", "/", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "token", Value: "c"}) }, "c", false},
+		{"query", "/?access_token=d", nil, "d", false},
+		{"auth header wins", "/?access_token=d", func(r *http.Request) { r.Header.Set("Authorization", "Bearer a") }, "a", false},
+		{"malformed header falls through", "/?access_token=d", func(r *http.Request) { r.Header.Set("Authorization", "Basic x") }, "d", false},
+		{"malformed header only", "/", func(r *http.Request) { r.Header.Set("Authorization", "Basic x") }, "", true},
+		{"nothing", "/", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.setup != nil {
+				tt.setup(ctx.Request)
+			}
+			got, err := extractToken(ctx, extractors)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("token = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTokenExtractors(t *testing.T) {
+	valid := signToken(t, "10086")
+	tests := []struct {
+		name       string
+		extractors []TokenExtractor
+		setup      func(*http.Request)
+		wantStatus int
+		wantCode   float64
+		wantUID    string
+	}{
+		{"default bearer", nil, bearer(valid), http.StatusOK, 0, "10086"},
+		{"default ignores cookie", nil, func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "token", Value: valid}) }, http.StatusUnauthorized, 401, ""},
+		{"default malformed scheme", nil, func(r *http.Request) { r.Header.Set("Authorization", "Token "+valid) }, http.StatusUnauthorized, 401, ""},
+		{"cookie extractor", []TokenExtractor{FromCookie("token")}, func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "token", Value: valid}) }, http.StatusOK, 0, "10086"},
+		{"custom header", []TokenExtractor{FromHeader("x-token")}, func(r *http.Request) { r.Header.Set("x-token", valid) }, http.StatusOK, 0, "10086"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw := ValidateTokenWithConfig(&ValidateTokenConfig{VerifierConfig: hmacVerifierConfig(), Extractors: tt.extractors})
+			res := runAuth(t, "/orders", "/orders", tt.setup, mw)
+			if res.status != tt.wantStatus || res.errCode != tt.wantCode || res.uid != tt.wantUID {
+				t.Errorf("status = %d, errCode = %v, uid = %q, want %d, %v, %q", res.status, res.errCode, res.uid, tt.wantStatus, tt.wantCode, tt.wantUID)
+			}
+		})
+	}
+}
@@ -1,110 +1,135 @@
 package middleware
 
 import (
-	"errors"
-	"net/http"
-
+	"context"
 	"strings"
-	"time"
 
 	"github.com/ccnj/go-utils/log"
+	"github.com/ccnj/go-utils/token"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-type myCustomClaims struct {
-	UID  string `json:"uid"`
-	Role int32  `json:"role"`
-	jwt.RegisteredClaims
+// ValidateTokenConfig ValidateTokenWithConfig 的配置
+type ValidateTokenConfig struct {
+	token.VerifierConfig               // 验证签名的 key 和允许的算法，与 token 包共用
+	SkipPathsPrefix      []string      // 不需要验证的路由前缀，按路径段匹配，等同于 Skip 中的 Prefix 规则
+	Skip                 *RouteMatcher // 不需要验证的路由，支持精确路径、前缀、通配符、gin 路由模板和请求方法
+	// Optional 可选登录的路由：带了 token 就验证并写入身份，没带则按匿名处理，用于登录后展示个性化内容的公开页面
+	Optional *RouteMatcher
+	// OptionalRejectInvalid Optional 路由上 token 无效（格式错误、过期、签名错误等）时返回 401；默认按匿名处理
+	OptionalRejectInvalid bool
+	// Extractors 按顺序尝试的 token 来源，为空时使用 DefaultTokenExtractors（Authorization: Bearer）
+	// 示例 []middleware.TokenExtractor{middleware.FromAuthHeader("Authorization", "Bearer"), middleware.FromHeader("x-token"), middleware.FromCookie("token")}
+	Extractors []TokenExtractor
+	// Errors 失败响应的提示语、errCode、WWW-Authenticate 和输出格式，为空时使用 DefaultAuthErrors
+	Errors *AuthErrorConfig
 }
 
-// claims.UID 用户id
-// claims.RegisteredClaims.ExpiresAt 过期时间
-func parseToken(tokenStr string, jwtSigningKey string) (claims *myCustomClaims, e error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &myCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtSigningKey), nil
-	}, jwt.WithLeeway(5*time.Second))
-	if err != nil {
-		log.Pure{}.Info("parse token err", "err", err)
-		if errors.Is(err, jwt.ErrTokenSignatureInvalid) {
-			// 签名错误，说明有人在攻击了，记录一下
-			log.Pure{}.Error("签名错误，疑似受到攻击",
-				"tokenStr", tokenStr,
-				"err", err,
-			)
-		}
-		return nil, err
-	} else if claims, ok := token.Claims.(*myCustomClaims); ok {
-		// fmt.Println(claims.UID, claims.RegisteredClaims.ExpiresAt)
-		return claims, nil
-	} else {
-		return nil, errors.New("未知的claims类型, 无法继续")
-	}
+// ValidateToken 使用单一 HMAC 密钥验证 token，只接受 HS256、HS384、HS512
+func ValidateToken(jwtSigningKey string, skipPathsPrefix []string) gin.HandlerFunc {
+	return ValidateTokenWithConfig(&ValidateTokenConfig{
+		VerifierConfig: token.VerifierConfig{
+			Keys:       token.HMACKey(jwtSigningKey),
+			Algorithms: token.HMACAlgorithms,
+		},
+		SkipPathsPrefix: skipPathsPrefix,
+	})
 }
 
-func canSkip(ctx *gin.Context, skipPathsPrefix []string) bool {
-	for _, path := range skipPathsPrefix {
-		if strings.HasPrefix(ctx.Request.URL.Path, path) {
-			return true
-		}
+// ValidateTokenWithConfig 验证 token，支持 RS256/ES256/EdDSA 等非对称算法和按 kid 选择 key
+// 网关只需持有公钥即可验证
+func ValidateTokenWithConfig(config *ValidateTokenConfig) gin.HandlerFunc {
+	verifier, err := token.NewVerifier(&config.VerifierConfig)
+	if err != nil {
+		panic(err)
+	}
+	skip := NewRouteMatcher()
+	for _, prefix := range config.SkipPathsPrefix {
+		skip.Add(Prefix(prefix))
+	}
+	if config.Skip != nil {
+		skip.Add(config.Skip.rules...)
+	}
+	optionalMatcher := config.Optional
+	optionalRejectInvalid := config.OptionalRejectInvalid
+	errs := config.Errors
+	extractors := config.Extractors
+	if len(extractors) == 0 {
+		extractors = DefaultTokenExtractors
 	}
-	return false
-}
 
-func ValidateToken(jwtSigningKey string, skipPathsPrefix []string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// 跳过不需要验证的路由
-		if canSkip(ctx, skipPathsPrefix) {
+		if _, ok := skip.Match(ctx); ok {
 			// ctx.Next() // 会自动执行，可以不显示写出来
 			ctx.Set("uid", "")
 			return
 		}
+		_, optional := optionalMatcher.Match(ctx)
+		// 可选登录路由上 token 无效时是否按匿名放行
+		anonymousOnInvalid := optional && !optionalRejectInvalid
 
-		// 获取请求头中的Authorization
-		authHeader := ctx.GetHeader("Authorization")
-		if authHeader == "" {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"errCode": 401,
-				"errMsg":  "尚未登录，请先登录～",
-			})
-			ctx.Abort() // 必须显式地中止。因为gin中，即使没有ctx.Next()，也会在中间件结束时自动执行下一个
+		// 按顺序从请求头、cookie、查询参数等位置提取Token
+		tokenStr, err := extractToken(ctx, extractors)
+		if err != nil {
+			if anonymousOnInvalid {
+				ctx.Set("uid", "")
+				return
+			}
+			errs.abort(ctx, FailureMalformedHeader, err)
 			return
 		}
-
-		// 从Authorization头中提取Token
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		if token == authHeader {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"errCode": 401,
-				"errMsg":  "授权头格式错误",
-			})
-			ctx.Abort()
+		if tokenStr == "" {
+			if optional {
+				ctx.Set("uid", "")
+				return
+			}
+			errs.abort(ctx, FailureMissingToken, nil)
 			return
 		}
 
 		// 验证token有效性
-		claims, err := parseToken(token, jwtSigningKey)
+		claims, err := verifier.VerifyContext(ctx, tokenStr)
 		if err != nil {
-			// 验证失败
-			var errMsg string
-			if errors.Is(err, jwt.ErrTokenExpired) {
-				errMsg = "身份认证已过期，请重新登录"
-			} else {
-				errMsg = "身份认证失败，请先登录"
+			logVerifyFailure(ctx, tokenStr, err)
+			reason := failureFromVerifyErr(err)
+			if reason == FailureUnavailable {
+				// 吊销存储故障，token 可能是有效的，不能让用户重新登录
+				errs.abort(ctx, reason, err)
+				return
+			}
+			if anonymousOnInvalid {
+				ctx.Set("uid", "")
+				return
 			}
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"errCode": 401,
-				"errMsg":  errMsg,
-			})
-			ctx.Abort()
+			errs.abort(ctx, reason, err)
 			return
 		}
 
-		// 保存uid至ctx中
-		ctx.Set("uid", claims.UID)
-		ctx.Set("role", int64(claims.Role)) // ctx无getInt32方法，所以存int64，取的时候也必须ctx.GetInt64("role") GetInt取不到
+		// 保存身份至ctx中，下游通过 PrincipalFrom(ctx) 读取
+		SetPrincipal(ctx, &Principal{
+			UID:    claims.UID,
+			Role:   claims.Role,
+			Scopes: strings.Fields(claims.Scope),
+			Claims: claims,
+			Token:  tokenStr,
+		})
 		// 执行后续中间件
 		// ctx.Next()
 
 	}
 }
+
+// logVerifyFailure 记录 token 验证失败及未通过的检查项，如 issuer、audience、expired
+// 签名错误说明有人在攻击了，按 Error 记录
+func logVerifyFailure(ctx context.Context, tokenStr string, err error, kv ...interface{}) {
+	kv = append(kv, "check", token.FailedCheck(err), "err", err)
+	switch token.FailedCheck(err) {
+	case token.CheckSignature:
+		log.Error(ctx, "签名错误，疑似受到攻击", append(kv, "tokenStr", tokenStr)...)
+	case token.CheckUnavailable:
+		log.Error(ctx, "查询 token 吊销状态失败", kv...)
+	default:
+		log.Info(ctx, "token 验证失败", kv...)
+	}
+}
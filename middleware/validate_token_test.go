@@ -0,0 +1,209 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ccnj/go-utils/token"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const testSigningKey = "0123456789abcdef0123456789abcdef"
+
+// signToken 用 testSigningKey 签发 access token
+func signToken(t *testing.T, uid string, mutate ...func(*token.Claims)) string {
+	t.Helper()
+	iss, err := token.NewIssuer(&token.IssuerConfig{SigningMethod: jwt.SigningMethodHS256, SigningKey: []byte(testSigningKey)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	claims := &token.Claims{UID: uid, Role: 1, RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(now)}}
+	for _, m := range mutate {
+		m(claims)
+	}
+	s, err := iss.IssueClaims(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// hmacVerifierConfig 与 signToken 配套的验证配置
+func hmacVerifierConfig() token.VerifierConfig {
+	return token.VerifierConfig{Keys: token.HMACKey(testSigningKey), Algorithms: token.HMACAlgorithms}
+}
+
+// authResult 请求经过中间件后的结果
+type authResult struct {
+	status  int
+	errCode float64
+	errMsg  string
+	uid     string // 到达 handler 时的 uid
+	header  http.Header
+}
+
+// runAuth 用中间件处理一次 GET 请求，最后的 handler 返回 uid
+func runAuth(t *testing.T, route, path string, setup func(*http.Request), handlers ...gin.HandlerFunc) authResult {
+	t.Helper()
+	r := gin.New()
+	handlers = append(handlers, func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"uid": ctx.GetString("uid")})
+	})
+	r.GET(route, handlers...)
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if setup != nil {
+		setup(req)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var body struct {
+		ErrCode float64 `json:"errCode"`
+		ErrMsg  string  `json:"errMsg"`
+		UID     string  `json:"uid"`
+	}
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid json body %q: %v", w.Body, err)
+		}
+	}
+	return authResult{status: w.Code, errCode: body.ErrCode, errMsg: body.ErrMsg, uid: body.UID, header: w.Header()}
+}
+
+func bearer(tokenStr string) func(*http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+tokenStr)
+	}
+}
+
+// failingRevocationStore 模拟吊销存储故障
+type failingRevocationStore struct {
+	*token.MemoryRevocationStore
+}
+
+func (failingRevocationStore) IsJTIRevoked(context.Context, string) (bool, error) {
+	return false, errors.New("redis: connection refused")
+}
+
+func TestValidateTokenRevocation(t *testing.T) {
+	ctx := context.Background()
+	store := token.NewMemoryRevocationStore(100)
+	_ = store.RevokeJTI(ctx, "revoked-jti", time.Now().Add(time.Hour))
+	_ = store.RevokeUser(ctx, "banned", time.Now())
+
+	withJTI := func(jti string) func(*token.Claims) {
+		return func(c *token.Claims) { c.ID = jti }
+	}
+	tests := []struct {
+		name       string
+		store      token.RevocationStore
+		token      string
+		wantStatus int
+		wantMsg    string
+		wantUID    string
+	}{
+		{"valid", store, signToken(t, "10086", withJTI("ok-jti")), http.StatusOK, "", "10086"},
+		{"revoked jti", store, signToken(t, "10086", withJTI("revoked-jti")), http.StatusUnauthorized, "登录状态已失效，请重新登录", ""},
+		{"revoked user", store, signToken(t, "banned", func(c *token.Claims) {
+			c.IssuedAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		}), http.StatusUnauthorized, "登录状态已失效，请重新登录", ""},
+		{"store failure", failingRevocationStore{store}, signToken(t, "10086", withJTI("ok-jti")), http.StatusInternalServerError, "服务繁忙，请稍后再试", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := hmacVerifierConfig()
+			cfg.Revocation = tt.store
+			mw := ValidateTokenWithConfig(&ValidateTokenConfig{VerifierConfig: cfg})
+			res := runAuth(t, "/orders", "/orders", bearer(tt.token), mw)
+			if res.status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", res.status, tt.wantStatus, res.errMsg)
+			}
+			if res.errMsg != tt.wantMsg || res.uid != tt.wantUID {
+				t.Errorf("errMsg = %q, uid = %q, want %q, %q", res.errMsg, res.uid, tt.wantMsg, tt.wantUID)
+			}
+			if tt.wantStatus == http.StatusInternalServerError && res.header.Get("WWW-Authenticate") != "" {
+				t.Error("500 response should not carry WWW-Authenticate")
+			}
+		})
+	}
+}
+
+func TestValidateTokenLogsFailureOnce(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     string
+		wantLevel zapcore.Level
+		wantMsg   string
+	}{
+		{"bad signature", signToken(t, "10086") + "x", zapcore.ErrorLevel, "签名错误，疑似受到攻击"},
+		{"expired", signToken(t, "10086", func(c *token.Claims) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+		}), zapcore.InfoLevel, "token 验证失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			defer zap.ReplaceGlobals(zap.New(core))()
+
+			res := runAuth(t, "/orders", "/orders", bearer(tt.token), ValidateToken(testSigningKey, nil))
+			if res.status != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want 401", res.status)
+			}
+			entries := logs.All()
+			if len(entries) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(entries))
+			}
+			if entries[0].Level != tt.wantLevel || entries[0].Message != tt.wantMsg {
+				t.Errorf("logged [%s] %q, want [%s] %q", entries[0].Level, entries[0].Message, tt.wantLevel, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestValidateTokenOptional(t *testing.T) {
+	valid := signToken(t, "10086")
+	expired := signToken(t, "10086", func(c *token.Claims) {
+		c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	})
+	tests := []struct {
+		name          string
+		rejectInvalid bool
+		path          string
+		setup         func(*http.Request)
+		wantStatus    int
+		wantUID       string
+	}{
+		{"optional with valid token", false, "/feed", bearer(valid), http.StatusOK, "10086"},
+		{"optional without token", false, "/feed", nil, http.StatusOK, ""},
+		{"optional with expired token", false, "/feed", bearer(expired), http.StatusOK, ""},
+		{"optional with malformed header", false, "/feed", func(r *http.Request) { r.Header.Set("Authorization", "Basic abc") }, http.StatusOK, ""},
+		{"optional reject invalid with expired token", true, "/feed", bearer(expired), http.StatusUnauthorized, ""},
+		{"optional reject invalid without token", true, "/feed", nil, http.StatusOK, ""},
+		{"optional reject invalid with malformed header", true, "/feed", func(r *http.Request) { r.Header.Set("Authorization", "Basic abc") }, http.StatusUnauthorized, ""},
+		{"required route without token", false, "/orders", nil, http.StatusUnauthorized, ""},
+		{"required route with valid token", false, "/orders", bearer(valid), http.StatusOK, "10086"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw := ValidateTokenWithConfig(&ValidateTokenConfig{
+				VerifierConfig:        hmacVerifierConfig(),
+				Optional:              NewRouteMatcher(Exact("/feed")),
+				OptionalRejectInvalid: tt.rejectInvalid,
+			})
+			res := runAuth(t, tt.path, tt.path, tt.setup, mw)
+			if res.status != tt.wantStatus || res.uid != tt.wantUID {
+				t.Errorf("status = %d, uid = %q, want %d, %q", res.status, res.uid, tt.wantStatus, tt.wantUID)
+			}
+		})
+	}
+}
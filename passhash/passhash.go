@@ -3,6 +3,7 @@ package passhash
 import (
 	"crypto/rand"
 	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base64"
 
 	"golang.org/x/crypto/pbkdf2"
@@ -56,7 +57,8 @@ func EasyHash(rawPwd string, saltLen int) (salt string, pwdHash string, err erro
 }
 
 // 验证密码是否正确，传入原始密码、哈希后的密码和盐
+// 使用常量时间比较，避免通过响应时间逐字节猜出哈希
 func Verify(rawPwd, pwdHash string, salt string) bool {
-	hash := pbkdf2.Key([]byte(rawPwd), []byte(salt), 100, 32, sha512.New)
-	return base64.StdEncoding.EncodeToString(hash) == pwdHash
+	hash := HashPassword(rawPwd, []byte(salt))
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(pwdHash)) == 1
 }
@@ -0,0 +1,33 @@
+package passhash
+
+import "testing"
+
+func TestVerify(t *testing.T) {
+	salt, hash, err := EasyHash("p@ssw0rd", 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(salt) != 16 {
+		t.Fatalf("salt length = %d, want 16", len(salt))
+	}
+	tests := []struct {
+		name   string
+		rawPwd string
+		hash   string
+		salt   string
+		want   bool
+	}{
+		{"correct password", "p@ssw0rd", hash, salt, true},
+		{"wrong password", "p@ssw0rD", hash, salt, false},
+		{"wrong salt", "p@ssw0rd", hash, salt + "x", false},
+		{"truncated hash", "p@ssw0rd", hash[:len(hash)-1], salt, false},
+		{"empty hash", "p@ssw0rd", "", salt, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.rawPwd, tt.hash, tt.salt); got != tt.want {
+				t.Errorf("Verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package token
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims access token 的声明，签发（Issuer）和验证（Verifier、middleware.ValidateToken）共用，避免各服务各自定义后对不上
+// claims.UID 用户id
+// claims.RegisteredClaims.ExpiresAt 过期时间
+type Claims struct {
+	UID   string `json:"uid"`
+	Role  int32  `json:"role"`
+	Scope string `json:"scope,omitempty"` // 以空格分隔的授权范围，如 "order:read order:write"
+	jwt.RegisteredClaims
+}
@@ -0,0 +1,99 @@
+package token
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// IssuerConfig 签发 access token 的配置
+type IssuerConfig struct {
+	Issuer        string            // iss，为空则不写入
+	Audience      []string          // aud，为空则不写入
+	TTL           time.Duration     // 有效期，默认 2 小时
+	KeyID         string            // 写入 header 的 kid，验证方据此选择公钥
+	SigningMethod jwt.SigningMethod // 如 jwt.SigningMethodRS256、jwt.SigningMethodES256、jwt.SigningMethodEdDSA、jwt.SigningMethodHS256
+	SigningKey    interface{}       // HS* 为 []byte，RS*/PS* 为 *rsa.PrivateKey，ES* 为 *ecdsa.PrivateKey，EdDSA 为 ed25519.PrivateKey
+}
+
+// Issuer 签发 access token，claims 与 middleware.ValidateToken 验证的完全一致
+type Issuer struct {
+	config IssuerConfig
+	now    func() time.Time
+}
+
+// NewIssuer 创建 token 签发器
+// 示例 token.NewIssuer(&token.IssuerConfig{Issuer: "user_srv", KeyID: "2024-01", SigningMethod: jwt.SigningMethodRS256, SigningKey: rsaKey})
+func NewIssuer(config *IssuerConfig) (*Issuer, error) {
+	cfg := *config
+	if cfg.SigningMethod == nil {
+		return nil, errors.New("token: IssuerConfig.SigningMethod is required")
+	}
+	if cfg.SigningKey == nil {
+		return nil, errors.New("token: IssuerConfig.SigningKey is required")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 2 * time.Hour
+	}
+	return &Issuer{config: cfg, now: time.Now}, nil
+}
+
+// Issue 为用户签发 access token，返回 token 字符串和写入的 claims
+func (i *Issuer) Issue(uid string, role int32) (string, *Claims, error) {
+	claims := &Claims{UID: uid, Role: role}
+	tokenStr, err := i.IssueClaims(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return tokenStr, claims, nil
+}
+
+// IssueClaims 签发自定义 claims，未设置的 iss、aud、sub、jti、iat、nbf、exp 按配置补全
+func (i *Issuer) IssueClaims(claims *Claims) (string, error) {
+	now := i.now()
+	rc := &claims.RegisteredClaims
+	if rc.Issuer == "" {
+		rc.Issuer = i.config.Issuer
+	}
+	if len(rc.Audience) == 0 && len(i.config.Audience) > 0 {
+		rc.Audience = jwt.ClaimStrings(i.config.Audience)
+	}
+	if rc.Subject == "" {
+		rc.Subject = claims.UID
+	}
+	if rc.ID == "" {
+		rc.ID = uuid.NewString()
+	}
+	if rc.IssuedAt == nil {
+		rc.IssuedAt = jwt.NewNumericDate(now)
+	}
+	if rc.NotBefore == nil {
+		rc.NotBefore = jwt.NewNumericDate(now)
+	}
+	if rc.ExpiresAt == nil {
+		rc.ExpiresAt = jwt.NewNumericDate(now.Add(i.config.TTL))
+	}
+
+	jwtToken := jwt.NewWithClaims(i.config.SigningMethod, claims)
+	if i.config.KeyID != "" {
+		jwtToken.Header["kid"] = i.config.KeyID
+	}
+	return jwtToken.SignedString(i.config.SigningKey)
+}
+
+// signRefresh 用相同的 key 签名 refresh token，header typ 与 access token 区分开
+func (i *Issuer) signRefresh(claims *refreshClaims) (string, error) {
+	jwtToken := jwt.NewWithClaims(i.config.SigningMethod, claims)
+	jwtToken.Header["typ"] = refreshJWTType
+	if i.config.KeyID != "" {
+		jwtToken.Header["kid"] = i.config.KeyID
+	}
+	return jwtToken.SignedString(i.config.SigningKey)
+}
+
+// TTL 返回 access token 的有效期，便于接口返回 expires_in
+func (i *Issuer) TTL() time.Duration {
+	return i.config.TTL
+}
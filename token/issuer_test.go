@@ -0,0 +1,101 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestIssuerRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		issuer    IssuerConfig
+		verifier  VerifierConfig
+		wantCheck string
+	}{
+		{
+			name:     "rs256 with kid",
+			issuer:   IssuerConfig{Issuer: "user_srv", Audience: []string{"app"}, KeyID: "rsa-1", SigningMethod: jwt.SigningMethodRS256, SigningKey: testRSAKey},
+			verifier: VerifierConfig{Keys: NewKeySet(Key{ID: "rsa-1", Algorithm: "RS256", Key: &testRSAKey.PublicKey}), Issuer: "user_srv", Audience: []string{"app"}},
+		},
+		{
+			name:     "hs256",
+			issuer:   IssuerConfig{SigningMethod: jwt.SigningMethodHS256, SigningKey: testHMACSecret},
+			verifier: VerifierConfig{Keys: HMACKey(string(testHMACSecret))},
+		},
+		{
+			name:      "verifier expects another issuer",
+			issuer:    IssuerConfig{Issuer: "order_srv", SigningMethod: jwt.SigningMethodHS256, SigningKey: testHMACSecret},
+			verifier:  VerifierConfig{Keys: HMACKey(string(testHMACSecret)), Issuer: "user_srv"},
+			wantCheck: CheckIssuer,
+		},
+		{
+			name:      "verifier has another key",
+			issuer:    IssuerConfig{KeyID: "rsa-1", SigningMethod: jwt.SigningMethodRS256, SigningKey: testOtherRSAKey},
+			verifier:  VerifierConfig{Keys: NewKeySet(Key{ID: "rsa-1", Algorithm: "RS256", Key: &testRSAKey.PublicKey})},
+			wantCheck: CheckSignature,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iss, err := NewIssuer(&tt.issuer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tokenStr, issued, err := iss.Issue("10086", 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v := mustVerifier(t, &tt.verifier)
+			claims, err := v.Verify(tokenStr)
+			if got := FailedCheck(err); got != tt.wantCheck {
+				t.Fatalf("FailedCheck = %q, want %q (err %v)", got, tt.wantCheck, err)
+			}
+			if err != nil {
+				return
+			}
+			if claims.UID != "10086" || claims.Role != 2 || claims.Subject != "10086" || claims.ID != issued.ID || claims.ID == "" {
+				t.Errorf("unexpected claims %+v", claims)
+			}
+			if got := claims.ExpiresAt.Sub(claims.IssuedAt.Time); got != 2*time.Hour {
+				t.Errorf("ttl = %s, want default 2h", got)
+			}
+		})
+	}
+}
+
+func TestIssueClaimsKeepsExplicitValues(t *testing.T) {
+	iss, err := NewIssuer(&IssuerConfig{Issuer: "user_srv", TTL: time.Minute, SigningMethod: jwt.SigningMethodHS256, SigningKey: testHMACSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	claims := &Claims{UID: "1", Scope: "order:read", RegisteredClaims: jwt.RegisteredClaims{ID: "my-jti", Issuer: "sso", ExpiresAt: jwt.NewNumericDate(exp)}}
+	if _, err := iss.IssueClaims(claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.ID != "my-jti" || claims.Issuer != "sso" || !claims.ExpiresAt.Equal(exp) {
+		t.Errorf("explicit claims were overwritten: %+v", claims.RegisteredClaims)
+	}
+	if iss.TTL() != time.Minute {
+		t.Errorf("TTL() = %s, want 1m", iss.TTL())
+	}
+}
+
+func TestNewIssuerConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *IssuerConfig
+	}{
+		{"missing signing method", &IssuerConfig{SigningKey: testHMACSecret}},
+		{"missing signing key", &IssuerConfig{SigningMethod: jwt.SigningMethodHS256}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewIssuer(tt.config); err == nil {
+				t.Error("NewIssuer should fail")
+			}
+		})
+	}
+}
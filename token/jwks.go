@@ -0,0 +1,390 @@
+package token
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ccnj/go-utils/log"
+)
+
+// JWKSConfig JWKS key 提供者的配置，URL 与 File 二选一
+type JWKSConfig struct {
+	URL                string        // JWKS 地址，如 https://auth.example.com/.well-known/jwks.json
+	File               string        // 本地 JWKS 文件路径，只有本地文件可以包含 oct 对称密钥
+	TTL                time.Duration // 缓存有效期，过期后下次验证时刷新，默认 10 分钟
+	MinRefreshInterval time.Duration // 遇到未知 kid 时强制刷新的最小间隔，防止伪造 kid 打爆 JWKS 服务，默认 1 分钟
+	Client             *http.Client  // 为空时使用 5 秒超时的默认 client，测试时可传 httptest.Server.Client()
+}
+
+// JWKS 从 URL 或文件加载 JSON Web Key Set 并缓存，实现 KeyProvider
+// 1. 缓存过期后刷新，URL 来源使用 ETag 避免重复下载；缓存中有匹配的 key 时在后台刷新，不阻塞请求
+// 2. 遇到未知 kid 时刷新一次（受 MinRefreshInterval 限制），轮换 key 后无需重新部署网关
+// 3. 刷新失败时继续使用上一次成功加载的 key
+// 4. 对称密钥（kty 为 oct）只接受本地文件来源，远程 JWKS 中的 oct key 会被跳过
+type JWKS struct {
+	config JWKSConfig
+	now    func() time.Time
+
+	mu          sync.RWMutex
+	keys        []jwkKey
+	etag        string
+	fetchedAt   time.Time // 最近一次成功加载（含 304）的时间
+	attemptedAt time.Time // 最近一次尝试刷新的时间
+
+	refreshMu  sync.Mutex     // 保证同一时间只有一个刷新请求
+	refreshing atomic.Bool    // 是否已有后台刷新在进行
+	background sync.WaitGroup // 后台刷新，测试中用于等待刷新完成
+}
+
+// NewJWKS 创建 JWKS key 提供者，会立即加载一次，首次加载失败时返回错误
+func NewJWKS(config *JWKSConfig) (*JWKS, error) {
+	cfg := *config
+	if (cfg.URL == "") == (cfg.File == "") {
+		return nil, errors.New("token: exactly one of JWKSConfig.URL and JWKSConfig.File is required")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 10 * time.Minute
+	}
+	if cfg.MinRefreshInterval <= 0 {
+		cfg.MinRefreshInterval = time.Minute
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 5 * time.Second}
+	}
+	j := &JWKS{config: cfg, now: time.Now}
+	if err := j.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *JWKS) VerifyKey(kid, alg string) (interface{}, error) {
+	now := j.now()
+
+	j.mu.RLock()
+	expired := now.Sub(j.fetchedAt) >= j.config.TTL
+	canRetry := now.Sub(j.attemptedAt) >= j.config.MinRefreshInterval
+	j.mu.RUnlock()
+
+	refreshed := false
+	if expired && canRetry {
+		// 缓存的 key 仍可用时不让请求等待刷新，刷新失败也会继续使用这把 key
+		if key, err := j.lookup(kid, alg); err == nil {
+			j.refreshInBackground()
+			return key, nil
+		}
+		j.refreshQuietly()
+		refreshed = true
+	}
+	if key, err := j.lookup(kid, alg); err == nil || !canRetry || refreshed {
+		return key, err
+	}
+
+	// 未知 kid，可能是签发方刚轮换了 key，刷新后再找一次
+	j.refreshQuietly()
+	return j.lookup(kid, alg)
+}
+
+// Refresh 重新加载 JWKS，失败时保留已有的 key
+// 距上一次尝试不足 MinRefreshInterval 时直接返回，并发的多个刷新只会请求一次
+func (j *JWKS) Refresh(ctx context.Context) error {
+	j.refreshMu.Lock()
+	defer j.refreshMu.Unlock()
+
+	// 等待 refreshMu 期间其他请求可能已经刷新过，需在锁内重新判断
+	j.mu.Lock()
+	now := j.now()
+	if !j.attemptedAt.IsZero() && now.Sub(j.attemptedAt) < j.config.MinRefreshInterval {
+		j.mu.Unlock()
+		return nil
+	}
+	j.attemptedAt = now
+	etag := j.etag
+	j.mu.Unlock()
+
+	var (
+		data    []byte
+		newETag string
+		err     error
+	)
+	if j.config.URL != "" {
+		data, newETag, err = j.fetchURL(ctx, etag)
+	} else {
+		data, newETag, err = j.readFile(etag)
+	}
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if data == nil { // 未变化
+		j.fetchedAt = j.now()
+		return nil
+	}
+	keys, err := parseJWKS(data, j.config.File != "")
+	if err != nil {
+		return err
+	}
+	j.keys = keys
+	j.etag = newETag
+	j.fetchedAt = j.now()
+	return nil
+}
+
+func (j *JWKS) refreshQuietly() {
+	if err := j.Refresh(context.Background()); err != nil {
+		log.Pure{}.Warn("刷新 JWKS 失败，继续使用上一次的 key", "url", j.config.URL, "file", j.config.File, "err", err)
+	}
+}
+
+// refreshInBackground 启动后台刷新，已有后台刷新在进行时直接返回
+func (j *JWKS) refreshInBackground() {
+	if !j.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	j.background.Add(1)
+	go func() {
+		defer j.background.Done()
+		defer j.refreshing.Store(false)
+		j.refreshQuietly()
+	}()
+}
+
+// fetchURL 返回 nil data 表示服务端返回 304，内容未变化
+func (j *JWKS) fetchURL(ctx context.Context, etag string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.config.URL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := j.config.Client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, etag, nil
+	case http.StatusOK:
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return nil, "", err
+		}
+		return data, resp.Header.Get("ETag"), nil
+	default:
+		return nil, "", fmt.Errorf("token: fetch JWKS %s: unexpected status %d", j.config.URL, resp.StatusCode)
+	}
+}
+
+// readFile 以文件修改时间和大小作为 ETag，未变化时不重新解析
+func (j *JWKS) readFile(etag string) ([]byte, string, error) {
+	info, err := os.Stat(j.config.File)
+	if err != nil {
+		return nil, "", err
+	}
+	newETag := fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())
+	if newETag == etag {
+		return nil, etag, nil
+	}
+	data, err := os.ReadFile(j.config.File)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, newETag, nil
+}
+
+func (j *JWKS) lookup(kid, alg string) (interface{}, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	var candidate *jwkKey
+	for i := range j.keys {
+		k := &j.keys[i]
+		if !k.supports(alg) {
+			continue
+		}
+		if kid != "" && k.kid == kid {
+			return k.key, nil
+		}
+		if kid == "" {
+			if candidate != nil {
+				// token 没有 kid 且有多把候选 key 时无法确定用哪把
+				return nil, fmt.Errorf("%w: token has no kid and JWKS has multiple %s keys", ErrKeyNotFound, alg)
+			}
+			candidate = k
+		}
+	}
+	if candidate != nil {
+		return candidate.key, nil
+	}
+	return nil, fmt.Errorf("%w: kid=%q alg=%q", ErrKeyNotFound, kid, alg)
+}
+
+// jwk JSON Web Key（RFC 7517）中用到的字段
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+type jwkKey struct {
+	kid string
+	alg string // JWK 中声明的算法，可为空
+	kty string
+	crv string
+	key interface{}
+}
+
+// supports 判断 key 能否用于验证 alg 算法的签名
+func (k *jwkKey) supports(alg string) bool {
+	if k.alg != "" {
+		return k.alg == alg
+	}
+	switch k.kty {
+	case "RSA":
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case "EC":
+		return (k.crv == "P-256" && alg == "ES256") ||
+			(k.crv == "P-384" && alg == "ES384") ||
+			(k.crv == "P-521" && alg == "ES512")
+	case "OKP":
+		return alg == "EdDSA"
+	case "oct":
+		return strings.HasPrefix(alg, "HS")
+	}
+	return false
+}
+
+// parseJWKS 解析 JWKS，跳过不支持或非签名用途的 key
+// allowSymmetric 为 false 时跳过 oct key：远程 JWKS 是公开的，其中的对称密钥任何人都能拿来签发 token
+func parseJWKS(data []byte, allowSymmetric bool) ([]jwkKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("token: invalid JWKS: %w", err)
+	}
+	keys := make([]jwkKey, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if k.Kty == "oct" && !allowSymmetric {
+			log.Pure{}.Warn("跳过远程 JWKS 中的对称密钥", "kid", k.Kid)
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			log.Pure{}.Warn("跳过无法解析的 JWK", "kid", k.Kid, "kty", k.Kty, "err", err)
+			continue
+		}
+		keys = append(keys, jwkKey{kid: k.Kid, alg: k.Alg, kty: k.Kty, crv: k.Crv, key: key})
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("token: JWKS contains no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (k *jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeB64(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeB64(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		var ecdhCurve ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeB64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeB64(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		// 借助 crypto/ecdh 校验点在曲线上
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) > size || len(y) > size {
+			return nil, errors.New("invalid EC point")
+		}
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		copy(point[1+size-len(x):1+size], x)
+		copy(point[1+2*size-len(y):], y)
+		if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeB64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		secret, err := decodeB64(k.K)
+		if err != nil {
+			return nil, err
+		}
+		return secret, nil
+	default:
+		return nil, fmt.Errorf("unsupported kty %q", k.Kty)
+	}
+}
+
+// decodeB64 JWK 使用不带填充的 base64url，兼容带填充的写法
+func decodeB64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,344 @@
+package token
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func rsaJWK(kid string, pub *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"alg": "RS256",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+func octJWK(kid string, secret []byte) map[string]string {
+	return map[string]string{"kty": "oct", "kid": kid, "alg": "HS256", "k": base64.RawURLEncoding.EncodeToString(secret)}
+}
+
+// jwksServer 可替换内容、可模拟故障的 JWKS 服务，按内容计算 ETag
+type jwksServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	body     []byte
+	fail     bool
+	hold     chan struct{} // 不为空时请求阻塞到 channel 关闭，模拟慢服务
+	requests int32
+	notMod   int32 // 返回 304 的次数
+}
+
+func newJWKSServer(t *testing.T, keys ...map[string]string) *jwksServer {
+	s := &jwksServer{}
+	s.setKeys(t, keys...)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.requests, 1)
+		s.mu.Lock()
+		body, fail, hold := s.body, s.fail, s.hold
+		s.mu.Unlock()
+		if hold != nil {
+			<-hold
+		}
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		sum := sha256.Sum256(body)
+		etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:8]) + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			atomic.AddInt32(&s.notMod, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) setKeys(t *testing.T, keys ...map[string]string) {
+	body, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.body = body
+	s.mu.Unlock()
+}
+
+func (s *jwksServer) setFail(fail bool) {
+	s.mu.Lock()
+	s.fail = fail
+	s.mu.Unlock()
+}
+
+// newTestJWKS 创建使用假时钟的 JWKS，返回推进时钟的函数
+func newTestJWKS(t *testing.T, srv *jwksServer) (*JWKS, func(time.Duration)) {
+	t.Helper()
+	j, err := NewJWKS(&JWKSConfig{URL: srv.URL, Client: srv.Client(), TTL: 10 * time.Minute, MinRefreshInterval: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	now := time.Now()
+	j.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	// NewJWKS 用真实时钟加载过一次，改用假时钟后重新对齐
+	j.mu.Lock()
+	j.fetchedAt, j.attemptedAt = now, now
+	j.mu.Unlock()
+	return j, func(d time.Duration) {
+		mu.Lock()
+		now = now.Add(d)
+		mu.Unlock()
+	}
+}
+
+func TestJWKSETagNotModified(t *testing.T) {
+	srv := newJWKSServer(t, rsaJWK("k1", &testRSAKey.PublicKey))
+	j, advance := newTestJWKS(t, srv)
+
+	tests := []struct {
+		name         string
+		advance      time.Duration
+		wantRequests int32
+		wantNotMod   int32
+	}{
+		{"cached within ttl", time.Minute, 1, 0},
+		{"revalidated with etag after ttl", 10 * time.Minute, 2, 1},
+		{"cached again after 304", time.Minute, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advance(tt.advance)
+			if _, err := j.VerifyKey("k1", "RS256"); err != nil {
+				t.Fatal(err)
+			}
+			j.background.Wait()
+			if got := atomic.LoadInt32(&srv.requests); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+			if got := atomic.LoadInt32(&srv.notMod); got != tt.wantNotMod {
+				t.Errorf("304 responses = %d, want %d", got, tt.wantNotMod)
+			}
+		})
+	}
+}
+
+func TestJWKSUnknownKid(t *testing.T) {
+	srv := newJWKSServer(t, rsaJWK("k1", &testRSAKey.PublicKey))
+	j, advance := newTestJWKS(t, srv)
+	advance(time.Minute)
+
+	// 签发方轮换 key
+	srv.setKeys(t, rsaJWK("k1", &testRSAKey.PublicKey), rsaJWK("k2", &testOtherRSAKey.PublicKey))
+
+	tests := []struct {
+		name         string
+		kid          string
+		wantErr      bool
+		wantRequests int32
+	}{
+		{"known kid needs no refresh", "k1", false, 1},
+		{"new kid refreshes", "k2", false, 2},
+		{"forged kid within min interval does not refresh", "forged", true, 2},
+		{"forged kid again", "forged-2", true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := j.VerifyKey(tt.kid, "RS256")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrKeyNotFound) {
+				t.Errorf("err = %v, want ErrKeyNotFound", err)
+			}
+			if tt.kid == "k2" && key.(*rsa.PublicKey).N.Cmp(testOtherRSAKey.N) != 0 {
+				t.Error("k2 resolved to the wrong key")
+			}
+			if got := atomic.LoadInt32(&srv.requests); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestJWKSConcurrentUnknownKidRefreshesOnce(t *testing.T) {
+	srv := newJWKSServer(t, rsaJWK("k1", &testRSAKey.PublicKey))
+	j, advance := newTestJWKS(t, srv)
+	advance(time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = j.VerifyKey("forged", "RS256")
+		}()
+	}
+	wg.Wait()
+	if got := atomic.LoadInt32(&srv.requests); got != 2 {
+		t.Errorf("requests = %d, want 2 (initial load + one refresh)", got)
+	}
+}
+
+func TestJWKSRefreshesInBackgroundAfterTTL(t *testing.T) {
+	srv := newJWKSServer(t, rsaJWK("k1", &testRSAKey.PublicKey))
+	j, advance := newTestJWKS(t, srv)
+	advance(11 * time.Minute)
+
+	hold := make(chan struct{})
+	srv.mu.Lock()
+	srv.hold = hold
+	srv.mu.Unlock()
+	srv.setKeys(t, rsaJWK("k1", &testRSAKey.PublicKey), rsaJWK("k2", &testOtherRSAKey.PublicKey))
+
+	// JWKS 服务卡住时，缓存中有的 kid 不等待刷新
+	done := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		go func() {
+			_, err := j.VerifyKey("k1", "RS256")
+			done <- err
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("VerifyKey blocked on the background refresh")
+		}
+	}
+
+	close(hold)
+	j.background.Wait()
+	if got := atomic.LoadInt32(&srv.requests); got != 2 {
+		t.Errorf("requests = %d, want 2 (initial load + one background refresh)", got)
+	}
+	// 后台刷新完成后新 key 可用，且在 MinRefreshInterval 内不会再次请求
+	if _, err := j.VerifyKey("k2", "RS256"); err != nil {
+		t.Errorf("k2 after background refresh: %v", err)
+	}
+	if got := atomic.LoadInt32(&srv.requests); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
+
+func TestJWKSFallbackToLastGoodKeys(t *testing.T) {
+	srv := newJWKSServer(t, rsaJWK("k1", &testRSAKey.PublicKey))
+	j, advance := newTestJWKS(t, srv)
+
+	tests := []struct {
+		name    string
+		setup   func()
+		kid     string
+		wantErr bool
+	}{
+		{"server down after ttl", func() { srv.setFail(true) }, "k1", false},
+		{"server returns garbage", func() {
+			srv.setFail(false)
+			srv.mu.Lock()
+			srv.body = []byte(`{"keys": "oops"}`)
+			srv.mu.Unlock()
+		}, "k1", false},
+		{"server returns only unusable keys", func() { srv.setKeys(t, octJWK("h1", testHMACSecret)) }, "k1", false},
+		{"unknown kid still rejected", func() { srv.setFail(true) }, "k9", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+			advance(11 * time.Minute)
+			before := atomic.LoadInt32(&srv.requests)
+			_, err := j.VerifyKey(tt.kid, "RS256")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			j.background.Wait()
+			if atomic.LoadInt32(&srv.requests) == before {
+				t.Error("expected a refresh attempt after ttl")
+			}
+		})
+	}
+}
+
+func TestJWKSSymmetricKeys(t *testing.T) {
+	set := map[string]interface{}{"keys": []map[string]string{octJWK("h1", testHMACSecret)}}
+	data, _ := json.Marshal(set)
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	srv := newJWKSServer(t, octJWK("h1", testHMACSecret))
+	mixed := newJWKSServer(t, octJWK("h1", testHMACSecret), rsaJWK("k1", &testRSAKey.PublicKey))
+
+	tests := []struct {
+		name       string
+		config     *JWKSConfig
+		kid, alg   string
+		wantNewErr bool
+		wantKeyErr bool
+	}{
+		{"oct from local file", &JWKSConfig{File: file}, "h1", "HS256", false, false},
+		{"only oct from url", &JWKSConfig{URL: srv.URL, Client: srv.Client()}, "", "", true, false},
+		{"oct skipped from url", &JWKSConfig{URL: mixed.URL, Client: mixed.Client()}, "h1", "HS256", false, true},
+		{"rsa kept from url", &JWKSConfig{URL: mixed.URL, Client: mixed.Client()}, "k1", "RS256", false, false},
+		{"url and file both set", &JWKSConfig{URL: srv.URL, File: file}, "", "", true, false},
+		{"neither url nor file", &JWKSConfig{}, "", "", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, err := NewJWKS(tt.config)
+			if (err != nil) != tt.wantNewErr {
+				t.Fatalf("NewJWKS err = %v, wantErr %v", err, tt.wantNewErr)
+			}
+			if err != nil {
+				return
+			}
+			_, err = j.VerifyKey(tt.kid, tt.alg)
+			if (err != nil) != tt.wantKeyErr {
+				t.Errorf("VerifyKey err = %v, wantErr %v", err, tt.wantKeyErr)
+			}
+		})
+	}
+}
+
+func TestJWKSRefreshRespectsMinInterval(t *testing.T) {
+	srv := newJWKSServer(t, rsaJWK("k1", &testRSAKey.PublicKey))
+	j, advance := newTestJWKS(t, srv)
+
+	if err := j.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&srv.requests); got != 1 {
+		t.Errorf("requests = %d, want 1: Refresh within MinRefreshInterval should be skipped", got)
+	}
+	advance(time.Minute)
+	if err := j.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&srv.requests); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
@@ -0,0 +1,74 @@
+package token
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrKeyNotFound 找不到与 token header 中 kid、alg 匹配的 key
+var ErrKeyNotFound = errors.New("token: no matching key for kid/alg")
+
+// HMACAlgorithms 原有的 HMAC 签名算法
+var HMACAlgorithms = []string{"HS256", "HS384", "HS512"}
+
+// KeyProvider 根据 token header 中的 kid 和 alg 提供验证签名用的 key
+// 返回值类型需与算法匹配：HS* 为 []byte，RS*/PS* 为 *rsa.PublicKey，ES* 为 *ecdsa.PublicKey，EdDSA 为 ed25519.PublicKey
+type KeyProvider interface {
+	VerifyKey(kid, alg string) (interface{}, error)
+}
+
+// Key 一把验证用的 key
+type Key struct {
+	ID        string      // kid，为空表示不限制 kid
+	Algorithm string      // 签名算法，如 RS256、ES256、EdDSA、HS256
+	Key       interface{} // 见 KeyProvider 说明
+}
+
+// KeySet 静态的 key 集合，按 kid 和 alg 选择 key
+// 同一个 kid 的 key 必须与 alg 一致，避免用 RSA 公钥当 HMAC 密钥这类算法混淆攻击
+type KeySet struct {
+	keys []Key
+}
+
+// NewKeySet 创建静态 key 集合
+// 示例 token.NewKeySet(token.Key{ID: "2024-01", Algorithm: "RS256", Key: rsaPub})
+func NewKeySet(keys ...Key) *KeySet {
+	return &KeySet{keys: keys}
+}
+
+func (s *KeySet) VerifyKey(kid, alg string) (interface{}, error) {
+	for _, k := range s.keys {
+		if (k.ID == "" || k.ID == kid) && k.Algorithm == alg {
+			return k.Key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: kid=%q alg=%q", ErrKeyNotFound, kid, alg)
+}
+
+// hmacKey 兼容原有的单一 HMAC 密钥，不区分 kid
+type hmacKey []byte
+
+// HMACKey 用单一的 HMAC 密钥验证 HS256、HS384、HS512 签名的 token
+func HMACKey(secret string) KeyProvider {
+	return hmacKey(secret)
+}
+
+func (k hmacKey) VerifyKey(kid, alg string) (interface{}, error) {
+	if !strings.HasPrefix(alg, "HS") {
+		return nil, fmt.Errorf("%w: kid=%q alg=%q", ErrKeyNotFound, kid, alg)
+	}
+	return []byte(k), nil
+}
+
+// ParsePublicKeyPEM 解析 PEM 格式（PKIX，"PUBLIC KEY"）的 RSA、ECDSA、Ed25519 公钥
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("token: invalid PEM data")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
@@ -0,0 +1,338 @@
+package token
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ccnj/go-utils/log"
+	"github.com/ccnj/go-utils/rand/cryptorand"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("token: invalid refresh token")
+	ErrRefreshTokenExpired = errors.New("token: refresh token expired")
+	// ErrRefreshTokenReused 已经使用过的 refresh token 再次被使用，说明可能被盗用，整个 family 已被吊销
+	ErrRefreshTokenReused = errors.New("token: refresh token reused, family revoked")
+)
+
+// refreshJWTType JWT 格式 refresh token 的 header typ，Verifier 据此拒绝把 refresh token 当 access token 使用
+const refreshJWTType = "rt+jwt"
+
+// RefreshFormat refresh token 的格式
+type RefreshFormat string
+
+const (
+	RefreshOpaque RefreshFormat = "opaque" // 随机字符串，存储中只保存其 sha256
+	RefreshJWT    RefreshFormat = "jwt"    // 用 Issuer 的 key 签名的 JWT，存储中按 jti 记录
+)
+
+// RefreshRecord 存储中的一条 refresh token 记录
+type RefreshRecord struct {
+	ID        string // opaque 格式为 token 的 sha256，JWT 格式为 jti
+	FamilyID  string // 同一次登录轮换出的所有 refresh token 属于同一个 family
+	UID       string
+	Role      int32
+	ExpiresAt time.Time
+	Used      bool
+}
+
+// RefreshStore refresh token 的存储，生产环境可用 Redis、MySQL 实现
+type RefreshStore interface {
+	Save(ctx context.Context, record *RefreshRecord) error
+	// Get 不存在时返回 nil, nil
+	Get(ctx context.Context, id string) (*RefreshRecord, error)
+	// MarkUsed 原子地把记录标记为已使用，返回标记前是否已被使用
+	MarkUsed(ctx context.Context, id string) (alreadyUsed bool, err error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	IsFamilyRevoked(ctx context.Context, familyID string) (bool, error)
+}
+
+// RefreshConfig RefreshManager 的配置
+type RefreshConfig struct {
+	Issuer   *Issuer       // 签发 access token，JWT 格式下也用它的 key 签名 refresh token，必填
+	Verifier *Verifier     // JWT 格式下验证 refresh token 签名，opaque 格式不需要
+	Store    RefreshStore  // 必填，测试可用 NewMemoryRefreshStore()
+	TTL      time.Duration // refresh token 有效期，默认 30 天
+	Format   RefreshFormat // 默认 RefreshOpaque
+}
+
+// TokenPair 登录或刷新后返回给客户端的 token
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"` // access token 剩余有效秒数
+}
+
+// refreshClaims JWT 格式 refresh token 的声明
+type refreshClaims struct {
+	FamilyID string `json:"fid"`
+	jwt.RegisteredClaims
+}
+
+// RefreshManager 签发和轮换 refresh token
+// 每次刷新都会作废旧的 refresh token 并签发新的；已作废的 token 再次出现时吊销整个 family
+type RefreshManager struct {
+	config RefreshConfig
+	now    func() time.Time
+}
+
+// NewRefreshManager 创建 refresh token 管理器
+func NewRefreshManager(config *RefreshConfig) (*RefreshManager, error) {
+	cfg := *config
+	if cfg.Issuer == nil {
+		return nil, errors.New("token: RefreshConfig.Issuer is required")
+	}
+	if cfg.Store == nil {
+		return nil, errors.New("token: RefreshConfig.Store is required")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 30 * 24 * time.Hour
+	}
+	if cfg.Format == "" {
+		cfg.Format = RefreshOpaque
+	}
+	if cfg.Format != RefreshOpaque && cfg.Format != RefreshJWT {
+		return nil, fmt.Errorf("token: unknown refresh token format %q", cfg.Format)
+	}
+	if cfg.Format == RefreshJWT && cfg.Verifier == nil {
+		return nil, errors.New("token: RefreshConfig.Verifier is required for JWT refresh tokens")
+	}
+	return &RefreshManager{config: cfg, now: time.Now}, nil
+}
+
+// Login 登录成功后调用，开启一个新的 family
+func (m *RefreshManager) Login(ctx context.Context, uid string, role int32) (*TokenPair, error) {
+	return m.issuePair(ctx, uuid.NewString(), uid, role)
+}
+
+// Rotate 用 refresh token 换取新的 access token 和 refresh token
+func (m *RefreshManager) Rotate(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	record, err := m.lookup(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if m.now().After(record.ExpiresAt) {
+		return nil, ErrRefreshTokenExpired
+	}
+
+	// 先保存新的 refresh token 再作废旧的：签发或保存失败时旧 token 仍未使用，客户端重试不会被当作重放
+	// 作废失败或发现重放时新 token 不会返回给客户端，重放时它所在的 family 也已被吊销
+	pair, err := m.issuePair(ctx, record.FamilyID, record.UID, record.Role)
+	if err != nil {
+		return nil, err
+	}
+	alreadyUsed, err := m.config.Store.MarkUsed(ctx, record.ID)
+	if err != nil {
+		return nil, err
+	}
+	if alreadyUsed {
+		// 旧 token 被再次使用：要么客户端重放，要么 token 被盗，无法区分，只能吊销整个 family 让用户重新登录
+		if err := m.config.Store.RevokeFamily(ctx, record.FamilyID); err != nil {
+			return nil, err
+		}
+		log.Pure{}.Warn("refresh token 被重复使用，已吊销整个 family", "uid", record.UID, "family_id", record.FamilyID)
+		return nil, ErrRefreshTokenReused
+	}
+	return pair, nil
+}
+
+// Revoke 退出登录时调用，吊销该 refresh token 所属的整个 family
+func (m *RefreshManager) Revoke(ctx context.Context, refreshToken string) error {
+	record, err := m.lookup(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	return m.config.Store.RevokeFamily(ctx, record.FamilyID)
+}
+
+// lookup 找到 refresh token 对应的记录，已吊销的 family 视为无效
+func (m *RefreshManager) lookup(ctx context.Context, refreshToken string) (*RefreshRecord, error) {
+	id, err := m.recordID(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	record, err := m.config.Store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrRefreshTokenInvalid
+	}
+	revoked, err := m.config.Store.IsFamilyRevoked(ctx, record.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrRefreshTokenInvalid
+	}
+	return record, nil
+}
+
+func (m *RefreshManager) recordID(refreshToken string) (string, error) {
+	if refreshToken == "" {
+		return "", ErrRefreshTokenInvalid
+	}
+	if m.config.Format == RefreshOpaque {
+		return hashRefreshToken(refreshToken), nil
+	}
+	claims := &refreshClaims{}
+	if err := m.config.Verifier.verifyRefresh(refreshToken, claims); err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return "", ErrRefreshTokenExpired
+		}
+		return "", fmt.Errorf("%w: %v", ErrRefreshTokenInvalid, err)
+	}
+	if claims.ID == "" || claims.FamilyID == "" {
+		return "", ErrRefreshTokenInvalid
+	}
+	return claims.ID, nil
+}
+
+func (m *RefreshManager) issuePair(ctx context.Context, familyID, uid string, role int32) (*TokenPair, error) {
+	accessToken, claims, err := m.config.Issuer.Issue(uid, role)
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	record := &RefreshRecord{
+		FamilyID:  familyID,
+		UID:       uid,
+		Role:      role,
+		ExpiresAt: now.Add(m.config.TTL),
+	}
+	var refreshToken string
+	if m.config.Format == RefreshOpaque {
+		refreshToken, err = cryptorand.GenCryptoRandStr(48)
+		if err != nil {
+			return nil, err
+		}
+		record.ID = hashRefreshToken(refreshToken)
+	} else {
+		record.ID = uuid.NewString()
+		refreshToken, err = m.config.Issuer.signRefresh(&refreshClaims{
+			FamilyID: familyID,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        record.ID,
+				Subject:   uid,
+				Issuer:    m.config.Issuer.config.Issuer,
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(record.ExpiresAt),
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := m.config.Store.Save(ctx, record); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(claims.ExpiresAt.Sub(now).Seconds()),
+	}, nil
+}
+
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryRefreshStore 内存实现的 RefreshStore，用于测试和单实例服务
+type MemoryRefreshStore struct {
+	mu       sync.Mutex
+	records  map[string]*RefreshRecord
+	families map[string]time.Time // 已吊销的 family 及其过期清理时间
+	saves    int
+	now      func() time.Time
+}
+
+func NewMemoryRefreshStore() *MemoryRefreshStore {
+	return &MemoryRefreshStore{
+		records:  make(map[string]*RefreshRecord),
+		families: make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+func (s *MemoryRefreshStore) Save(ctx context.Context, record *RefreshRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saves++
+	if s.saves%1024 == 0 {
+		s.gc()
+	}
+	r := *record
+	s.records[r.ID] = &r
+	return nil
+}
+
+func (s *MemoryRefreshStore) Get(ctx context.Context, id string) (*RefreshRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *r
+	return &copied, nil
+}
+
+func (s *MemoryRefreshStore) MarkUsed(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[id]
+	if !ok {
+		return false, ErrRefreshTokenInvalid
+	}
+	alreadyUsed := r.Used
+	r.Used = true
+	return alreadyUsed, nil
+}
+
+func (s *MemoryRefreshStore) RevokeFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// family 中最晚过期的 token 过期后，吊销记录就可以清理了
+	expiresAt := s.now()
+	for _, r := range s.records {
+		if r.FamilyID == familyID && r.ExpiresAt.After(expiresAt) {
+			expiresAt = r.ExpiresAt
+		}
+	}
+	s.families[familyID] = expiresAt
+	return nil
+}
+
+func (s *MemoryRefreshStore) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.families[familyID]
+	return ok, nil
+}
+
+// gc 清理已过期的记录，调用方需持有锁
+func (s *MemoryRefreshStore) gc() {
+	now := s.now()
+	for id, r := range s.records {
+		if now.After(r.ExpiresAt) {
+			delete(s.records, id)
+		}
+	}
+	for id, exp := range s.families {
+		if now.After(exp) {
+			delete(s.families, id)
+		}
+	}
+}
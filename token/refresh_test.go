@@ -0,0 +1,211 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestRefreshManager(t *testing.T, format RefreshFormat) (*RefreshManager, *Verifier) {
+	t.Helper()
+	iss, err := NewIssuer(&IssuerConfig{Issuer: "user_srv", KeyID: "rsa-1", SigningMethod: jwt.SigningMethodRS256, SigningKey: testRSAKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := mustVerifier(t, &VerifierConfig{Keys: NewKeySet(Key{ID: "rsa-1", Algorithm: "RS256", Key: &testRSAKey.PublicKey})})
+	m, err := NewRefreshManager(&RefreshConfig{Issuer: iss, Verifier: v, Store: NewMemoryRefreshStore(), Format: format})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, v
+}
+
+func TestRefreshRotation(t *testing.T) {
+	ctx := context.Background()
+	for _, format := range []RefreshFormat{RefreshOpaque, RefreshJWT} {
+		t.Run(string(format), func(t *testing.T) {
+			m, v := newTestRefreshManager(t, format)
+			login, err := m.Login(ctx, "10086", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims, err := v.Verify(login.AccessToken); err != nil || claims.UID != "10086" {
+				t.Fatalf("login access token invalid: %v", err)
+			}
+
+			// 每一步的 refresh token 取自之前的结果
+			var rotated, afterReuse *TokenPair
+			steps := []struct {
+				name    string
+				token   func() string
+				save    **TokenPair
+				wantErr error
+			}{
+				{"rotate login token", func() string { return login.RefreshToken }, &rotated, nil},
+				{"reuse rotated-out token", func() string { return login.RefreshToken }, nil, ErrRefreshTokenReused},
+				{"family revoked after reuse", func() string { return rotated.RefreshToken }, &afterReuse, ErrRefreshTokenInvalid},
+				{"empty token", func() string { return "" }, nil, ErrRefreshTokenInvalid},
+				{"unknown token", func() string { return "not-a-refresh-token" }, nil, ErrRefreshTokenInvalid},
+				{"access token as refresh token", func() string { return login.AccessToken }, nil, ErrRefreshTokenInvalid},
+			}
+			for _, s := range steps {
+				pair, err := m.Rotate(ctx, s.token())
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("%s: err = %v, want %v", s.name, err, s.wantErr)
+				}
+				if s.save != nil {
+					*s.save = pair
+				}
+				if err == nil {
+					if pair.RefreshToken == s.token() || pair.TokenType != "Bearer" || pair.ExpiresIn <= 0 {
+						t.Fatalf("%s: unexpected pair %+v", s.name, pair)
+					}
+					if claims, err := v.Verify(pair.AccessToken); err != nil || claims.UID != "10086" || claims.Role != 1 {
+						t.Fatalf("%s: rotated access token invalid: %v", s.name, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// flakyRefreshStore Save 可按需失败，模拟存储抖动
+type flakyRefreshStore struct {
+	*MemoryRefreshStore
+	failSave bool
+}
+
+func (s *flakyRefreshStore) Save(ctx context.Context, record *RefreshRecord) error {
+	if s.failSave {
+		return errStoreDown
+	}
+	return s.MemoryRefreshStore.Save(ctx, record)
+}
+
+func TestRefreshRotateRetryAfterSaveFailure(t *testing.T) {
+	ctx := context.Background()
+	for _, format := range []RefreshFormat{RefreshOpaque, RefreshJWT} {
+		t.Run(string(format), func(t *testing.T) {
+			m, _ := newTestRefreshManager(t, format)
+			store := &flakyRefreshStore{MemoryRefreshStore: NewMemoryRefreshStore()}
+			m.config.Store = store
+			login, err := m.Login(ctx, "10086", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			store.failSave = true
+			if _, err := m.Rotate(ctx, login.RefreshToken); !errors.Is(err, errStoreDown) {
+				t.Fatalf("rotate with failing store: err = %v, want %v", err, errStoreDown)
+			}
+
+			// 存储恢复后客户端用同一个 refresh token 重试，不应被当作重放
+			store.failSave = false
+			retried, err := m.Rotate(ctx, login.RefreshToken)
+			if err != nil {
+				t.Fatalf("retry after save failure: %v", err)
+			}
+			if _, err := m.Rotate(ctx, retried.RefreshToken); err != nil {
+				t.Errorf("family should not be revoked, rotate new token: %v", err)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenRejectedAsAccessToken(t *testing.T) {
+	m, v := newTestRefreshManager(t, RefreshJWT)
+	pair, err := m.Login(context.Background(), "10086", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		token     string
+		wantCheck string
+	}{
+		{"access token", pair.AccessToken, ""},
+		{"rt+jwt refresh token", pair.RefreshToken, CheckKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(tt.token)
+			if got := FailedCheck(err); got != tt.wantCheck {
+				t.Errorf("FailedCheck = %q, want %q (err %v)", got, tt.wantCheck, err)
+			}
+		})
+	}
+}
+
+func TestRefreshExpiryAndRevoke(t *testing.T) {
+	ctx := context.Background()
+	for _, format := range []RefreshFormat{RefreshOpaque, RefreshJWT} {
+		t.Run(string(format), func(t *testing.T) {
+			m, _ := newTestRefreshManager(t, format)
+
+			expiring, err := m.Login(ctx, "1", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			revoked, err := m.Login(ctx, "2", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := m.Login(ctx, "3", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Revoke(ctx, revoked.RefreshToken); err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				name    string
+				token   string
+				later   time.Duration
+				wantErr error
+			}{
+				{"revoked family", revoked.RefreshToken, 0, ErrRefreshTokenInvalid},
+				{"other family unaffected by revoke", other.RefreshToken, 0, nil},
+				{"expired", expiring.RefreshToken, 31 * 24 * time.Hour, ErrRefreshTokenExpired},
+			}
+			for _, tt := range tests {
+				if tt.later > 0 {
+					// JWT 格式的过期由 jwt 库按真实时间判断，这里只能验证 opaque 格式的记录过期
+					if format == RefreshJWT {
+						continue
+					}
+					m.now = func() time.Time { return time.Now().Add(tt.later) }
+				}
+				if _, err := m.Rotate(ctx, tt.token); !errors.Is(err, tt.wantErr) {
+					t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestNewRefreshManagerConfigErrors(t *testing.T) {
+	iss, err := NewIssuer(&IssuerConfig{SigningMethod: jwt.SigningMethodHS256, SigningKey: testHMACSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		config *RefreshConfig
+	}{
+		{"missing issuer", &RefreshConfig{Store: NewMemoryRefreshStore()}},
+		{"missing store", &RefreshConfig{Issuer: iss}},
+		{"unknown format", &RefreshConfig{Issuer: iss, Store: NewMemoryRefreshStore(), Format: "paseto"}},
+		{"jwt without verifier", &RefreshConfig{Issuer: iss, Store: NewMemoryRefreshStore(), Format: RefreshJWT}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRefreshManager(tt.config); err == nil {
+				t.Error("NewRefreshManager should fail")
+			}
+		})
+	}
+}
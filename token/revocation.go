@@ -0,0 +1,147 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTokenRevoked token 签名有效，但已被吊销（退出登录、修改密码、封号等）
+var ErrTokenRevoked = errors.New("token: token has been revoked")
+
+// RevocationStore token 吊销记录的存储
+// Redis 实现示例：RevokeJTI 用 SET revoked:jti:<jti> 1 EXAT <exp>，RevokeUser 用 SET revoked:uid:<uid> <unix>
+type RevocationStore interface {
+	// RevokeJTI 吊销单个 token，expiresAt 之后 token 本身已过期，记录可以清理
+	RevokeJTI(ctx context.Context, jti string, expiresAt time.Time) error
+	IsJTIRevoked(ctx context.Context, jti string) (bool, error)
+	// RevokeUser 吊销用户在 before 之前签发的所有 token，用于退出所有设备、修改密码、封号
+	RevokeUser(ctx context.Context, uid string, before time.Time) error
+	// UserRevokedBefore 返回用户的吊销时间点，没有记录时返回零值
+	UserRevokedBefore(ctx context.Context, uid string) (time.Time, error)
+}
+
+// checkRevoked 在签名验证通过后调用
+func checkRevoked(ctx context.Context, store RevocationStore, claims *Claims) error {
+	if claims.ID != "" {
+		revoked, err := store.IsJTIRevoked(ctx, claims.ID)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+	before, err := store.UserRevokedBefore(ctx, claims.UID)
+	if err != nil {
+		return err
+	}
+	if !before.IsZero() {
+		// 没有 iat 的 token 无法判断签发时间，保守地视为已吊销
+		// iat 只精确到秒，吊销时间也截断到秒比较，吊销后同一秒内重新登录签发的 token 仍然有效
+		// 代价是吊销前同一秒内签发的 token 不会被吊销
+		if claims.IssuedAt == nil || claims.IssuedAt.Time.Before(before.Truncate(time.Second)) {
+			return ErrTokenRevoked
+		}
+	}
+	return nil
+}
+
+// MemoryRevocationStore 内存实现的 RevocationStore，按 LRU 淘汰，用于测试和单实例服务
+// 容量满时最久未访问的记录会被淘汰，被淘汰的 token 会重新变为有效，容量需按吊销量预估
+type MemoryRevocationStore struct {
+	jtis *lruCache
+	uids *lruCache
+}
+
+// NewMemoryRevocationStore capacity 为 jti 和 uid 各自的容量上限，小于等于 0 时为 10000
+func NewMemoryRevocationStore(capacity int) *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		jtis: newLRUCache(capacity),
+		uids: newLRUCache(capacity),
+	}
+}
+
+func (s *MemoryRevocationStore) RevokeJTI(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.jtis.set(jti, true, expiresAt)
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsJTIRevoked(ctx context.Context, jti string) (bool, error) {
+	_, ok := s.jtis.get(jti)
+	return ok, nil
+}
+
+func (s *MemoryRevocationStore) RevokeUser(ctx context.Context, uid string, before time.Time) error {
+	s.uids.set(uid, before, time.Time{})
+	return nil
+}
+
+func (s *MemoryRevocationStore) UserRevokedBefore(ctx context.Context, uid string) (time.Time, error) {
+	v, ok := s.uids.get(uid)
+	if !ok {
+		return time.Time{}, nil
+	}
+	return v.(time.Time), nil
+}
+
+// CachedRevocationStore 在远程存储（如 Redis）前加一层本地缓存，避免每个请求都查一次
+// 本地写入会立即生效；其他实例写入的吊销最多延迟 ttl 才能被本实例看到
+type CachedRevocationStore struct {
+	store RevocationStore
+	ttl   time.Duration
+	jtis  *lruCache
+	uids  *lruCache
+	now   func() time.Time
+}
+
+// NewCachedRevocationStore ttl 为本地缓存时间，建议几秒到几十秒；capacity 为本地缓存条数上限
+func NewCachedRevocationStore(store RevocationStore, ttl time.Duration, capacity int) *CachedRevocationStore {
+	return &CachedRevocationStore{
+		store: store,
+		ttl:   ttl,
+		jtis:  newLRUCache(capacity),
+		uids:  newLRUCache(capacity),
+		now:   time.Now,
+	}
+}
+
+func (s *CachedRevocationStore) RevokeJTI(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := s.store.RevokeJTI(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+	s.jtis.set(jti, true, s.now().Add(s.ttl))
+	return nil
+}
+
+func (s *CachedRevocationStore) IsJTIRevoked(ctx context.Context, jti string) (bool, error) {
+	if v, ok := s.jtis.get(jti); ok {
+		return v.(bool), nil
+	}
+	revoked, err := s.store.IsJTIRevoked(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+	s.jtis.set(jti, revoked, s.now().Add(s.ttl))
+	return revoked, nil
+}
+
+func (s *CachedRevocationStore) RevokeUser(ctx context.Context, uid string, before time.Time) error {
+	if err := s.store.RevokeUser(ctx, uid, before); err != nil {
+		return err
+	}
+	s.uids.set(uid, before, s.now().Add(s.ttl))
+	return nil
+}
+
+func (s *CachedRevocationStore) UserRevokedBefore(ctx context.Context, uid string) (time.Time, error) {
+	if v, ok := s.uids.get(uid); ok {
+		return v.(time.Time), nil
+	}
+	before, err := s.store.UserRevokedBefore(ctx, uid)
+	if err != nil {
+		return time.Time{}, err
+	}
+	s.uids.set(uid, before, s.now().Add(s.ttl))
+	return before, nil
+}
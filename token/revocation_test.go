@@ -0,0 +1,151 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// brokenRevocationStore 模拟 Redis 故障
+type brokenRevocationStore struct{}
+
+var errStoreDown = errors.New("redis: connection refused")
+
+func (brokenRevocationStore) RevokeJTI(context.Context, string, time.Time) error { return errStoreDown }
+func (brokenRevocationStore) IsJTIRevoked(context.Context, string) (bool, error) {
+	return false, errStoreDown
+}
+func (brokenRevocationStore) RevokeUser(context.Context, string, time.Time) error {
+	return errStoreDown
+}
+func (brokenRevocationStore) UserRevokedBefore(context.Context, string) (time.Time, error) {
+	return time.Time{}, errStoreDown
+}
+
+func TestVerifierRevocation(t *testing.T) {
+	ctx := context.Background()
+	second := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	store := NewMemoryRevocationStore(100)
+	_ = store.RevokeJTI(ctx, "revoked-jti", time.Now().Add(time.Hour))
+	// 用户 10086 在 second+700ms 退出所有设备
+	_ = store.RevokeUser(ctx, "10086", second.Add(700*time.Millisecond))
+
+	withClaims := func(uid, jti string, iat *jwt.NumericDate) string {
+		return signTestToken(t, jwt.SigningMethodHS256, testHMACSecret, "", func(c *Claims) {
+			c.UID, c.ID, c.IssuedAt = uid, jti, iat
+		})
+	}
+	tests := []struct {
+		name      string
+		store     RevocationStore
+		failOpen  bool
+		token     string
+		wantCheck string
+	}{
+		{"not revoked", store, false, withClaims("1", "jti-1", jwt.NewNumericDate(time.Now())), ""},
+		{"revoked by jti", store, false, withClaims("1", "revoked-jti", jwt.NewNumericDate(time.Now())), CheckRevoked},
+		{"issued before user revocation", store, false, withClaims("10086", "a", jwt.NewNumericDate(second.Add(-time.Second))), CheckRevoked},
+		{"reissued in the same second as revocation", store, false, withClaims("10086", "b", jwt.NewNumericDate(second.Add(900*time.Millisecond))), ""},
+		{"issued after user revocation", store, false, withClaims("10086", "c", jwt.NewNumericDate(second.Add(time.Second))), ""},
+		{"revoked user without iat", store, false, withClaims("10086", "d", nil), CheckRevoked},
+		{"other user without iat", store, false, withClaims("2", "e", nil), ""},
+		{"store failure", brokenRevocationStore{}, false, withClaims("1", "jti-1", jwt.NewNumericDate(time.Now())), CheckUnavailable},
+		{"store failure with fail open", brokenRevocationStore{}, true, withClaims("1", "jti-1", jwt.NewNumericDate(time.Now())), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := mustVerifier(t, &VerifierConfig{Keys: HMACKey(string(testHMACSecret)), Revocation: tt.store, RevocationFailOpen: tt.failOpen})
+			_, err := v.VerifyContext(ctx, tt.token)
+			if got := FailedCheck(err); got != tt.wantCheck {
+				t.Fatalf("FailedCheck = %q, want %q (err %v)", got, tt.wantCheck, err)
+			}
+			if tt.wantCheck == CheckRevoked && !errors.Is(err, ErrTokenRevoked) {
+				t.Errorf("err = %v, want ErrTokenRevoked", err)
+			}
+			if tt.wantCheck == CheckUnavailable && !errors.Is(err, errStoreDown) {
+				t.Errorf("err = %v, want the store error", err)
+			}
+		})
+	}
+}
+
+func TestCachedRevocationStore(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryRevocationStore(100)
+	local := NewCachedRevocationStore(remote, 10*time.Second, 100)
+	now := time.Now()
+	local.now = func() time.Time { return now }
+	local.jtis.now = local.now
+	local.uids.now = local.now
+
+	// 缓存未命中的结果
+	if revoked, _ := local.IsJTIRevoked(ctx, "jti-1"); revoked {
+		t.Fatal("jti-1 should not be revoked yet")
+	}
+	// 其他实例吊销
+	_ = remote.RevokeJTI(ctx, "jti-1", now.Add(time.Hour))
+	_ = remote.RevokeUser(ctx, "10086", now)
+	// 本实例吊销
+	_ = local.RevokeJTI(ctx, "jti-2", now.Add(time.Hour))
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		check   func() (bool, error)
+		want    bool
+	}{
+		{"local revoke is immediate", 0, func() (bool, error) { return local.IsJTIRevoked(ctx, "jti-2") }, true},
+		{"remote revoke hidden by cached miss", time.Second, func() (bool, error) { return local.IsJTIRevoked(ctx, "jti-1") }, false},
+		{"remote revoke visible after ttl", 10 * time.Second, func() (bool, error) { return local.IsJTIRevoked(ctx, "jti-1") }, true},
+		{"user revocation read through", 0, func() (bool, error) {
+			before, err := local.UserRevokedBefore(ctx, "10086")
+			return !before.IsZero(), err
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			got, err := tt.check()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	broken := NewCachedRevocationStore(brokenRevocationStore{}, time.Second, 10)
+	if _, err := broken.IsJTIRevoked(ctx, "x"); !errors.Is(err, errStoreDown) {
+		t.Errorf("IsJTIRevoked err = %v, want store error", err)
+	}
+	if err := broken.RevokeUser(ctx, "x", now); !errors.Is(err, errStoreDown) {
+		t.Errorf("RevokeUser err = %v, want store error", err)
+	}
+}
+
+func TestMemoryRevocationStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRevocationStore(2)
+	for _, jti := range []string{"a", "b", "c"} {
+		_ = store.RevokeJTI(ctx, jti, time.Now().Add(time.Hour))
+	}
+	_ = store.RevokeJTI(ctx, "expired", time.Now().Add(-time.Second))
+	tests := []struct {
+		jti  string
+		want bool
+	}{
+		{"a", false}, // 容量为 2，最早的被淘汰
+		{"c", true},
+		{"expired", false},
+	}
+	for _, tt := range tests {
+		if got, _ := store.IsJTIRevoked(ctx, tt.jti); got != tt.want {
+			t.Errorf("IsJTIRevoked(%q) = %v, want %v", tt.jti, got, tt.want)
+		}
+	}
+}
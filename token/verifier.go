@@ -0,0 +1,271 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ccnj/go-utils/log"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// token 未通过的检查项，见 ValidationError.Check
+const (
+	CheckMalformed     = "malformed"      // 格式错误，无法解析
+	CheckKey           = "key"            // 找不到验证 key（未知 kid、不允许的 token 类型等）
+	CheckSignature     = "signature"      // 签名错误或签名算法不在允许列表中
+	CheckExpired       = "expired"        // exp 已过期
+	CheckNotValidYet   = "not_valid_yet"  // nbf 或 iat 在未来
+	CheckIssuer        = "issuer"         // iss 与配置不符
+	CheckAudience      = "audience"       // aud 与配置不符
+	CheckRequiredClaim = "required_claim" // 缺少必需的声明
+	CheckMaxAge        = "max_age"        // 签发时间距今超过 MaxAge
+	CheckRevoked       = "revoked"        // 已被吊销
+	CheckUnavailable   = "unavailable"    // 吊销存储不可用，无法判断，与 token 本身无关
+	CheckInvalid       = "invalid"        // 其他原因
+)
+
+// ErrTokenTooOld 签发时间距今超过 VerifierConfig.MaxAge
+var ErrTokenTooOld = errors.New("token: token is too old")
+
+// ValidationError 说明 token 没有通过哪一项检查，可用 errors.Is 判断底层错误，如 jwt.ErrTokenExpired
+type ValidationError struct {
+	Check string // 见 Check* 常量
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("token check %q failed: %v", e.Check, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// FailedCheck 返回 err 对应的检查项，err 为 nil 时返回空串，不是 ValidationError 时返回 CheckInvalid
+func FailedCheck(err error) string {
+	if err == nil {
+		return ""
+	}
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		return ve.Check
+	}
+	return CheckInvalid
+}
+
+// VerifierConfig 验证 token 的配置
+type VerifierConfig struct {
+	Keys       KeyProvider     // 验证签名的 key，如 token.HMACKey(secret)、token.NewKeySet(...)、token.NewJWKS(...)
+	Algorithms []string        // 允许的签名算法，如 []string{"RS256", "ES256"}，必填
+	Revocation RevocationStore // 可选，签名验证通过后检查 token 是否已被吊销
+	// RevocationFailOpen 吊销存储不可用时放行，默认拒绝；为 true 时 Redis 故障不会导致所有用户掉线
+	RevocationFailOpen bool
+
+	Issuer   string   // 期望的 iss，为空则不检查，防止其他产品签发的 token 被接受
+	Audience []string // 期望的 aud，token 的 aud 中包含任意一个即可，为空则不检查
+	// RequiredClaims 必须存在的声明，可选 exp、nbf、iat、jti、sub、iss、aud；uid 始终必须非空
+	// role 为 int32，无法区分缺失与 0，不能作为必须声明
+	RequiredClaims []string
+	MaxAge         time.Duration // 签发时间距今的最大时长，为 0 不检查；设置后 iat 必须存在
+	Leeway         time.Duration // 时间相关检查允许的误差，默认 5 秒，小于 0 表示不允许误差
+}
+
+// Verifier 验证 token 签名和有效期，解析出 Claims
+type Verifier struct {
+	keys               KeyProvider
+	options            []jwt.ParserOption
+	revocation         RevocationStore
+	revocationFailOpen bool
+	audience           []string
+	requiredClaims     []string
+	maxAge             time.Duration
+	leeway             time.Duration
+	now                func() time.Time
+}
+
+// NewVerifier 创建 token 验证器
+func NewVerifier(config *VerifierConfig) (*Verifier, error) {
+	if config.Keys == nil {
+		return nil, errors.New("token: VerifierConfig.Keys is required")
+	}
+	if len(config.Algorithms) == 0 {
+		return nil, errors.New("token: VerifierConfig.Algorithms is required")
+	}
+	for _, c := range config.RequiredClaims {
+		switch c {
+		case "exp", "nbf", "iat", "jti", "sub", "iss", "aud", "uid":
+		case "role":
+			return nil, errors.New("token: role cannot be a required claim, a missing role is indistinguishable from role 0")
+		default:
+			return nil, fmt.Errorf("token: unsupported required claim %q", c)
+		}
+	}
+	leeway := config.Leeway
+	if leeway == 0 {
+		leeway = 5 * time.Second
+	} else if leeway < 0 {
+		leeway = 0
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithLeeway(leeway),
+		jwt.WithValidMethods(config.Algorithms),
+		jwt.WithIssuedAt(), // iat 在未来的 token 同样拒绝
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	return &Verifier{
+		keys:               config.Keys,
+		revocation:         config.Revocation,
+		revocationFailOpen: config.RevocationFailOpen,
+		options:            options,
+		audience:           config.Audience,
+		requiredClaims:     config.RequiredClaims,
+		maxAge:             config.MaxAge,
+		leeway:             leeway,
+		now:                time.Now,
+	}, nil
+}
+
+// Verify 只接受允许的签名算法，并按 header 中的 kid、alg 选择验证 key
+// refresh token 不能当作 access token 使用
+// 验证失败时返回 *ValidationError，可用 FailedCheck 取得未通过的检查项；Verifier 不记录日志，由调用方按需记录
+func (v *Verifier) Verify(tokenStr string) (*Claims, error) {
+	return v.VerifyContext(context.Background(), tokenStr)
+}
+
+// VerifyContext 同 Verify，ctx 用于查询吊销存储
+func (v *Verifier) VerifyContext(ctx context.Context, tokenStr string) (*Claims, error) {
+	jwtToken, err := v.parse(tokenStr, &Claims{}, false)
+	if err != nil {
+		return nil, &ValidationError{Check: classifyJWTError(err), Err: err}
+	}
+	claims, ok := jwtToken.Claims.(*Claims)
+	if !ok {
+		return nil, &ValidationError{Check: CheckInvalid, Err: errors.New("未知的claims类型, 无法继续")}
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	if v.revocation != nil {
+		if err := checkRevoked(ctx, v.revocation, claims); err != nil {
+			if errors.Is(err, ErrTokenRevoked) {
+				return nil, &ValidationError{Check: CheckRevoked, Err: err}
+			}
+			if !v.revocationFailOpen {
+				return nil, &ValidationError{Check: CheckUnavailable, Err: err}
+			}
+			// 放行时调用方看不到这个错误，只能在这里记录
+			log.Pure{}.Error("查询 token 吊销状态失败，按配置放行", "uid", claims.UID, "jti", claims.ID, "err", err)
+		}
+	}
+	return claims, nil
+}
+
+// checkClaims jwt 库未覆盖的检查：多个候选 aud、必需声明、最大签发时长
+func (v *Verifier) checkClaims(claims *Claims) error {
+	if len(v.audience) > 0 && !containsAny(claims.Audience, v.audience) {
+		return &ValidationError{Check: CheckAudience, Err: jwt.ErrTokenInvalidAudience}
+	}
+
+	if claims.UID == "" {
+		return missingClaim("uid")
+	}
+	for _, name := range v.requiredClaims {
+		if !hasClaim(claims, name) {
+			return missingClaim(name)
+		}
+	}
+
+	if v.maxAge > 0 {
+		if claims.IssuedAt == nil {
+			return missingClaim("iat")
+		}
+		if v.now().Sub(claims.IssuedAt.Time) > v.maxAge+v.leeway {
+			return &ValidationError{Check: CheckMaxAge, Err: ErrTokenTooOld}
+		}
+	}
+	return nil
+}
+
+func missingClaim(name string) error {
+	return &ValidationError{Check: CheckRequiredClaim, Err: fmt.Errorf("%w: %s", jwt.ErrTokenRequiredClaimMissing, name)}
+}
+
+func hasClaim(claims *Claims, name string) bool {
+	switch name {
+	case "exp":
+		return claims.ExpiresAt != nil
+	case "nbf":
+		return claims.NotBefore != nil
+	case "iat":
+		return claims.IssuedAt != nil
+	case "jti":
+		return claims.ID != ""
+	case "sub":
+		return claims.Subject != ""
+	case "iss":
+		return claims.Issuer != ""
+	case "aud":
+		return len(claims.Audience) > 0
+	case "uid":
+		return claims.UID != ""
+	}
+	return false
+}
+
+func containsAny(have []string, want []string) bool {
+	for _, h := range have {
+		for _, w := range want {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func classifyJWTError(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return CheckMalformed
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return CheckSignature
+	case errors.Is(err, jwt.ErrTokenUnverifiable):
+		return CheckKey
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return CheckExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return CheckNotValidYet
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return CheckIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return CheckAudience
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return CheckRequiredClaim
+	default:
+		return CheckInvalid
+	}
+}
+
+// verifyRefresh 验证 JWT 格式的 refresh token，只接受 header typ 为 refresh 的 token
+func (v *Verifier) verifyRefresh(tokenStr string, claims *refreshClaims) error {
+	_, err := v.parse(tokenStr, claims, true)
+	return err
+}
+
+var errTokenType = errors.New("token: unexpected token type")
+
+func (v *Verifier) parse(tokenStr string, claims jwt.Claims, refresh bool) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
+		typ, _ := t.Header["typ"].(string)
+		if (typ == refreshJWTType) != refresh {
+			return nil, errTokenType
+		}
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.VerifyKey(kid, t.Method.Alg())
+	}, v.options...)
+}
@@ -0,0 +1,237 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var (
+	testRSAKey, _        = rsa.GenerateKey(rand.Reader, 2048)
+	testECKey, _         = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, testEdKey, _      = ed25519.GenerateKey(rand.Reader)
+	testOtherRSAKey, _   = rsa.GenerateKey(rand.Reader, 2048)
+	testHMACSecret       = []byte("0123456789abcdef0123456789abcdef")
+	testOtherHMACSecret  = []byte("fedcba9876543210fedcba9876543210")
+	testDefaultAlgorithm = []string{"RS256", "ES256", "EdDSA", "HS256"}
+)
+
+// signTestToken 签发一个未过期的 access token，kid 为空时不写入 header
+func signTestToken(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, mutate ...func(*Claims)) string {
+	t.Helper()
+	now := time.Now()
+	claims := &Claims{
+		UID:  "10086",
+		Role: 1,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti-1",
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+	for _, m := range mutate {
+		m(claims)
+	}
+	tok := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func mustVerifier(t *testing.T, config *VerifierConfig) *Verifier {
+	t.Helper()
+	if config.Algorithms == nil {
+		config.Algorithms = testDefaultAlgorithm
+	}
+	v, err := NewVerifier(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestVerifierKeySet(t *testing.T) {
+	keys := NewKeySet(
+		Key{ID: "rsa-1", Algorithm: "RS256", Key: &testRSAKey.PublicKey},
+		Key{ID: "ec-1", Algorithm: "ES256", Key: &testECKey.PublicKey},
+		Key{ID: "ed-1", Algorithm: "EdDSA", Key: testEdKey.Public()},
+	)
+	tests := []struct {
+		name       string
+		algorithms []string
+		token      string
+		wantCheck  string // 为空表示验证通过
+	}{
+		{"rs256", nil, signTestToken(t, jwt.SigningMethodRS256, testRSAKey, "rsa-1"), ""},
+		{"es256", nil, signTestToken(t, jwt.SigningMethodES256, testECKey, "ec-1"), ""},
+		{"eddsa", nil, signTestToken(t, jwt.SigningMethodEdDSA, testEdKey, "ed-1"), ""},
+		{"unknown kid", nil, signTestToken(t, jwt.SigningMethodRS256, testRSAKey, "rsa-2"), CheckKey},
+		{"kid of another algorithm", nil, signTestToken(t, jwt.SigningMethodRS256, testRSAKey, "ec-1"), CheckKey},
+		{"wrong private key", nil, signTestToken(t, jwt.SigningMethodRS256, testOtherRSAKey, "rsa-1"), CheckSignature},
+		{"algorithm not allowed", []string{"ES256"}, signTestToken(t, jwt.SigningMethodRS256, testRSAKey, "rsa-1"), CheckSignature},
+		{"hmac signed with rsa public key", nil, signTestToken(t, jwt.SigningMethodHS256, x509.MarshalPKCS1PublicKey(&testRSAKey.PublicKey), "rsa-1"), CheckKey},
+		{"malformed", nil, "not.a.token", CheckMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := mustVerifier(t, &VerifierConfig{Keys: keys, Algorithms: tt.algorithms})
+			claims, err := v.Verify(tt.token)
+			if got := FailedCheck(err); got != tt.wantCheck {
+				t.Fatalf("FailedCheck = %q, want %q (err %v)", got, tt.wantCheck, err)
+			}
+			if err == nil && claims.UID != "10086" {
+				t.Errorf("uid = %q, want 10086", claims.UID)
+			}
+		})
+	}
+}
+
+func TestVerifierHMACKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     string
+		wantCheck string
+	}{
+		{"hs256", signTestToken(t, jwt.SigningMethodHS256, testHMACSecret, ""), ""},
+		{"kid is ignored", signTestToken(t, jwt.SigningMethodHS256, testHMACSecret, "any"), ""},
+		{"wrong secret", signTestToken(t, jwt.SigningMethodHS256, testOtherHMACSecret, ""), CheckSignature},
+		{"asymmetric token", signTestToken(t, jwt.SigningMethodRS256, testRSAKey, ""), CheckKey},
+		{"none algorithm", signTestToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, ""), CheckSignature},
+	}
+	v := mustVerifier(t, &VerifierConfig{Keys: HMACKey(string(testHMACSecret))})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(tt.token)
+			if got := FailedCheck(err); got != tt.wantCheck {
+				t.Fatalf("FailedCheck = %q, want %q (err %v)", got, tt.wantCheck, err)
+			}
+		})
+	}
+}
+
+func TestParsePublicKeyPEM(t *testing.T) {
+	der, err := x509.MarshalPKIXPublicKey(&testECKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"pkix public key", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), false},
+		{"not pem", []byte("hello"), true},
+		{"garbage der", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("xx")}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParsePublicKeyPEM(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if _, ok := key.(*ecdsa.PublicKey); !ok {
+					t.Errorf("key type = %T, want *ecdsa.PublicKey", key)
+				}
+			}
+		})
+	}
+}
+
+func TestNewVerifierConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *VerifierConfig
+	}{
+		{"missing keys", &VerifierConfig{Algorithms: []string{"HS256"}}},
+		{"missing algorithms", &VerifierConfig{Keys: HMACKey("x")}},
+		{"unsupported required claim", &VerifierConfig{Keys: HMACKey("x"), Algorithms: []string{"HS256"}, RequiredClaims: []string{"email"}}},
+		{"role as required claim", &VerifierConfig{Keys: HMACKey("x"), Algorithms: []string{"HS256"}, RequiredClaims: []string{"exp", "role"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewVerifier(tt.config); err == nil {
+				t.Error("NewVerifier should fail")
+			}
+		})
+	}
+}
+
+func TestVerifierClaims(t *testing.T) {
+	now := time.Now()
+	sign := func(mutate func(*Claims)) string {
+		return signTestToken(t, jwt.SigningMethodHS256, testHMACSecret, "", mutate)
+	}
+	tests := []struct {
+		name      string
+		config    VerifierConfig
+		token     string
+		wantCheck string
+	}{
+		{"issuer matches", VerifierConfig{Issuer: "user_srv"}, sign(func(c *Claims) { c.Issuer = "user_srv" }), ""},
+		{"issuer differs", VerifierConfig{Issuer: "user_srv"}, sign(func(c *Claims) { c.Issuer = "other" }), CheckIssuer},
+		{"issuer missing", VerifierConfig{Issuer: "user_srv"}, sign(func(c *Claims) {}), CheckRequiredClaim},
+		{"any audience matches", VerifierConfig{Audience: []string{"web", "app"}}, sign(func(c *Claims) { c.Audience = jwt.ClaimStrings{"app"} }), ""},
+		{"audience differs", VerifierConfig{Audience: []string{"web", "app"}}, sign(func(c *Claims) { c.Audience = jwt.ClaimStrings{"admin"} }), CheckAudience},
+		{"audience missing", VerifierConfig{Audience: []string{"web"}}, sign(func(c *Claims) {}), CheckAudience},
+		{"uid always required", VerifierConfig{}, sign(func(c *Claims) { c.UID = "" }), CheckRequiredClaim},
+		{"required jti present", VerifierConfig{RequiredClaims: []string{"jti", "exp"}}, sign(func(c *Claims) {}), ""},
+		{"required jti missing", VerifierConfig{RequiredClaims: []string{"jti"}}, sign(func(c *Claims) { c.ID = "" }), CheckRequiredClaim},
+		{"required sub missing", VerifierConfig{RequiredClaims: []string{"sub"}}, sign(func(c *Claims) {}), CheckRequiredClaim},
+		{"expired", VerifierConfig{}, sign(func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute)) }), CheckExpired},
+		{"expired within leeway", VerifierConfig{}, sign(func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-2 * time.Second)) }), ""},
+		{"expired without leeway", VerifierConfig{Leeway: -1}, sign(func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-2 * time.Second)) }), CheckExpired},
+		{"not valid yet", VerifierConfig{}, sign(func(c *Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(time.Minute)) }), CheckNotValidYet},
+		{"issued in the future", VerifierConfig{}, sign(func(c *Claims) { c.IssuedAt = jwt.NewNumericDate(now.Add(time.Minute)) }), CheckNotValidYet},
+		{"within max age", VerifierConfig{MaxAge: time.Hour}, sign(func(c *Claims) { c.IssuedAt = jwt.NewNumericDate(now.Add(-30 * time.Minute)) }), ""},
+		{"older than max age", VerifierConfig{MaxAge: time.Hour}, sign(func(c *Claims) { c.IssuedAt = jwt.NewNumericDate(now.Add(-2 * time.Hour)) }), CheckMaxAge},
+		{"max age requires iat", VerifierConfig{MaxAge: time.Hour}, sign(func(c *Claims) { c.IssuedAt = nil }), CheckRequiredClaim},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.config
+			cfg.Keys = HMACKey(string(testHMACSecret))
+			v := mustVerifier(t, &cfg)
+			_, err := v.Verify(tt.token)
+			if got := FailedCheck(err); got != tt.wantCheck {
+				t.Fatalf("FailedCheck = %q, want %q (err %v)", got, tt.wantCheck, err)
+			}
+		})
+	}
+}
+
+func TestVerifyDoesNotLog(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	v := mustVerifier(t, &VerifierConfig{Keys: HMACKey(string(testHMACSecret)), Audience: []string{"web"}})
+	for _, tokenStr := range []string{
+		"not.a.token",
+		signTestToken(t, jwt.SigningMethodHS256, testOtherHMACSecret, ""),
+		signTestToken(t, jwt.SigningMethodHS256, testHMACSecret, ""),
+	} {
+		if _, err := v.Verify(tokenStr); err == nil {
+			t.Fatal("Verify should fail")
+		}
+	}
+	if logs.Len() != 0 {
+		t.Errorf("Verify logged %d entries, failures should only be logged by the caller", logs.Len())
+	}
+}